@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// What an agent gets to see when it has to choose a move. Agents may use game.makeMove() and
+// game.reverseLastMove() to look ahead, but must leave the game exactly as they found it.
+type GameView struct {
+	game   *GameState
+	player Player
+	// The tiles that will be drawn after the current one, sorted by id. See newGameView()
+	remaining []Tile
+}
+
+// Returns the view of the player on the game with the drawn tile. The remaining tiles are sorted by id,
+// so agents know which tiles are left, but not the order in which they will be drawn.
+func newGameView(game *GameState, player Player) GameView {
+	remaining := append([]Tile(nil), game.tiles[game.drawnTile+1:]...)
+	sort.SliceStable(remaining, func(i, j int) bool { return remaining[i].id < remaining[j].id })
+	return GameView{game, player, remaining}
+}
+
+// An Agent decides which move a player makes. moves are all possible moves for the drawn tile
+// and are never empty. One agent per seat is given to runGame().
+type Agent interface {
+	ChooseMove(view GameView, tile Tile, moves []Move) Move
+}
+
+type RandomAgent struct{}
+
+type FirstMoveAgent struct{}
+
+// Plays the move with the most immediate points, see selectBestMove()
+type GreedyAgent struct{}
+
+type MCTSAgent struct {
+	config MCTSConfig
+}
+
+type ExpectimaxAgent struct {
+	config ExpectimaxConfig
+}
+
+// Agents that can end the game before the deck is empty, like a human without any more input.
+// runGame() asks them with ChooseTurn() instead of ChooseMove() and stops the game on TURN_QUIT.
+type QuittingAgent interface {
+	Agent
+	ChooseTurn(view GameView, tile Tile, moves []Move) (Move, int)
+}
+
+// Asks a human for every move
+type HumanAgent struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (a RandomAgent) ChooseMove(view GameView, tile Tile, moves []Move) Move {
+	return moves[view.game.rng.Intn(len(moves))]
+}
+
+func (a FirstMoveAgent) ChooseMove(view GameView, tile Tile, moves []Move) Move {
+	return moves[0]
+}
+
+func (a GreedyAgent) ChooseMove(view GameView, tile Tile, moves []Move) Move {
+	return view.game.selectBestMove(moves, view.player)
+}
+
+func (a MCTSAgent) ChooseMove(view GameView, tile Tile, moves []Move) Move {
+	return view.game.selectMCTSMove(moves, view.player, view.remaining, a.config)
+}
+
+func (a ExpectimaxAgent) ChooseMove(view GameView, tile Tile, moves []Move) Move {
+	return view.game.selectExpectimaxMove(moves, view.player, view.remaining, a.config)
+}
+
+func newHumanAgent(in io.Reader, out io.Writer) HumanAgent {
+	return HumanAgent{bufio.NewReader(in), out}
+}
+
+// There is no move to return at the end of the input, so this panics then. Callers that can't handle
+// that use ChooseTurn(), which returns TURN_QUIT instead, like runGame() does.
+func (a HumanAgent) ChooseMove(view GameView, tile Tile, moves []Move) Move {
+	move, action := a.ChooseTurn(view, tile, moves)
+	if action == TURN_QUIT {
+		panic("HumanAgent.ChooseMove: the input ended without a move, use ChooseTurn() to stop the game instead")
+	}
+	return move
+}
+
+// Returns TURN_QUIT at the end of the input, there is nobody left to ask then.
+func (a HumanAgent) ChooseTurn(view GameView, tile Tile, moves []Move) (Move, int) {
+	writeField(a.out, view.game.board)
+	fmt.Fprintf(a.out, "%v drew %v\n", view.player, tile)
+	for i, m := range moves {
+		fmt.Fprintf(a.out, "%3d: %v %v %v\n", i, m.pos, m.tile.sides, m.tile.meeple)
+	}
+
+	for {
+		fmt.Fprintf(a.out, "Move [0-%v]: ", len(moves)-1)
+		line, err := a.in.ReadString('\n')
+		if i, convErr := strconv.Atoi(strings.TrimSpace(line)); convErr == nil && i >= 0 && i < len(moves) {
+			return moves[i], TURN_MOVE
+		}
+		if err != nil {
+			return Move{}, TURN_QUIT
+		}
+	}
+}
+
+// Creates an agent by name. Used to configure the seats from the command line. The search agents take
+// options after the name, separated by colons, like "mcts:iterations=5000:rollout=greedy". A label after
+// @ only names the agent in the ratings, see agentRatingName().
+func newAgent(name string) (Agent, error) {
+	if strings.HasSuffix(name, "@") {
+		return nil, fmt.Errorf("agent %q: the label after @ is empty", name)
+	}
+	name = unlabeledAgentName(name)
+
+	// An external bot, see bot.go. The command may contain colons of its own
+	if strings.HasPrefix(name, "bot:") {
+		command := strings.Fields(strings.TrimPrefix(name, "bot:"))
+		if len(command) == 0 {
+			return nil, fmt.Errorf("agent %q: the bot command is missing", name)
+		}
+		return newBotAgent(defaultBotConfig(command)), nil
+	}
+
+	parts := strings.Split(name, ":")
+	var agent Agent
+	switch parts[0] {
+	case "random":
+		agent = RandomAgent{}
+	case "first":
+		agent = FirstMoveAgent{}
+	case "greedy":
+		agent = GreedyAgent{}
+	case "human":
+		agent = newHumanAgent(os.Stdin, os.Stdout)
+	case "mcts":
+		config := defaultMCTSConfig()
+		if err := setAgentOptions(name, parts[1:], config.options()); err != nil {
+			return nil, err
+		}
+		return MCTSAgent{config}, nil
+	case "expectimax":
+		config := defaultExpectimaxConfig()
+		if err := setAgentOptions(name, parts[1:], config.options()); err != nil {
+			return nil, err
+		}
+		return ExpectimaxAgent{config}, nil
+	default:
+		return nil, fmt.Errorf("unknown agent %q", name)
+	}
+	if len(parts) > 1 {
+		return nil, fmt.Errorf("agent %q: %v has no options", name, parts[0])
+	}
+	return agent, nil
+}
+
+// Returns the name of the agent without its label for the ratings, like "greedy" for "greedy@v2".
+func unlabeledAgentName(name string) string {
+	agent, _, _ := strings.Cut(name, "@")
+	return agent
+}
+
+// Sets the options, given as key=value, with the setter of the key.
+func setAgentOptions(name string, options []string, setters map[string]func(value string) error) error {
+	for _, option := range options {
+		key, value, ok := strings.Cut(option, "=")
+		set, known := setters[key]
+		if !ok || !known {
+			var keys []string
+			for k := range setters {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return fmt.Errorf("agent %q: unknown option %q, expected key=value with the keys %v", name, option, strings.Join(keys, ", "))
+		}
+		if err := set(value); err != nil {
+			return fmt.Errorf("agent %q: option %v: %w", name, key, err)
+		}
+	}
+	return nil
+}
+
+// Setters for agent options of the common types. All numbers are counts or limits, so they can't be negative.
+func intOption(p *int) func(string) error {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("expected a number of at least 0, got %q", value)
+		}
+		*p = n
+		return nil
+	}
+}
+
+func floatOption(p *float64) func(string) error {
+	return func(value string) (err error) {
+		*p, err = strconv.ParseFloat(value, 64)
+		return
+	}
+}
+
+func boolOption(p *bool) func(string) error {
+	return func(value string) (err error) {
+		*p, err = strconv.ParseBool(value)
+		return
+	}
+}
+
+// Sets the option to the index of the value in names.
+func enumOption(p *int, names ...string) func(string) error {
+	return func(value string) error {
+		for i, n := range names {
+			if value == n {
+				*p = i
+				return nil
+			}
+		}
+		return fmt.Errorf("expected one of %v, got %q", strings.Join(names, ", "), value)
+	}
+}
+
+// Creates one agent per player. A game has between 1 and MAX_PLAYERS players.
+func newAgents(names []string) ([]Agent, error) {
+	if len(names) == 0 || len(names) > MAX_PLAYERS {
+		return nil, fmt.Errorf("a game needs between 1 and %v agents, got %v", MAX_PLAYERS, len(names))
+	}
+	var agents []Agent
+	for _, name := range names {
+		agent, err := newAgent(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, agent)
+	}
+	return agents, nil
+}
+
+// Plays the game with one agent per player until the deck is empty and scores the end of the game.
+// Returns false if an agent quit before, the game is not scored then.
+func runGame(game *GameState, agents []Agent) bool {
+	for {
+		tile, moves, ok := game.drawTile()
+		if !ok {
+			break
+		}
+		player := game.players[game.currentPlayer]
+		view := newGameView(game, player)
+
+		agent := agents[player.index]
+		if q, ok := agent.(QuittingAgent); ok {
+			move, action := q.ChooseTurn(view, tile, moves)
+			if action == TURN_QUIT {
+				endGame(game, agents)
+				return false
+			}
+			game.makeMove(move)
+			continue
+		}
+		game.makeMove(agent.ChooseMove(view, tile, moves))
+	}
+
+	game.finalizeGame()
+	endGame(game, agents)
+	return true
+}
+
+// Agents that hold resources, like the process of a bot, are told when they are no longer needed.
+type EndingAgent interface {
+	Agent
+	EndGame(game *GameState)
+}
+
+func endGame(game *GameState, agents []Agent) {
+	for _, a := range agents {
+		if e, ok := a.(EndingAgent); ok {
+			e.EndGame(game)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAgentsChooseLegalMoves(t *testing.T) {
+	agents := []Agent{RandomAgent{}, FirstMoveAgent{}, GreedyAgent{}}
+
+	game := generateInitialBoard(len(agents), 0)
+	for i, tile := range game.tiles[:30] {
+		player := game.players[i%len(agents)]
+		moves := generatePossibleMoves(game.board, []Tile{tile}, game.openPlacements, player, len(game.players))
+		if len(moves) == 0 {
+			continue
+		}
+		view := GameView{&game, player, game.tiles[i+1:]}
+		move := agents[player.index].ChooseMove(view, tile, moves)
+		possible := false
+		for _, m := range moves {
+			possible = possible || m == move
+		}
+		if !possible {
+			t.Fatalf("%T chose a move that isn't possible: %v", agents[player.index], move)
+		}
+		game.makeMove(move)
+	}
+}
+
+func TestHumanAgent(t *testing.T) {
+	game := generateInitialBoard(2, 0)
+	tile := game.tiles[0]
+	moves := generatePossibleMoves(game.board, []Tile{tile}, game.openPlacements, game.players[0], len(game.players))
+
+	var out bytes.Buffer
+	agent := newHumanAgent(strings.NewReader("nope\n-1\n2\n"), &out)
+	move := agent.ChooseMove(GameView{&game, game.players[0], game.tiles[1:]}, tile, moves)
+	if move != moves[2] {
+		t.Errorf("Expected move 2, got %v", move)
+	}
+	if strings.Count(out.String(), "Move [") != 3 {
+		t.Errorf("Expected to be asked three times:\n%v", out.String())
+	}
+
+	// The end of the input ends the game instead of playing a move
+	agent = newHumanAgent(strings.NewReader("nope\n"), &out)
+	if _, action := agent.ChooseTurn(GameView{&game, game.players[0], game.tiles[1:]}, tile, moves); action != TURN_QUIT {
+		t.Errorf("Expected TURN_QUIT at the end of the input, got %v", action)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("ChooseMove() should not make up a move at the end of the input")
+			}
+		}()
+		newHumanAgent(strings.NewReader(""), &out).ChooseMove(GameView{&game, game.players[0], game.tiles[1:]}, tile, moves)
+	}()
+	if runGame(&game, []Agent{FirstMoveAgent{}, agent}) {
+		t.Errorf("The game should end when the human has no more input")
+	}
+	if len(game.lastMoves) != 1 || len(game.board) != 2 {
+		t.Errorf("Only the first move should be made and the game must not be scored: %v moves", len(game.lastMoves))
+	}
+}
+
+func TestGameViewHidesDeckOrder(t *testing.T) {
+	game := generateInitialBoard(2, 4)
+	game.drawTile()
+	view := newGameView(&game, game.players[0])
+
+	deck := countTileIds(game.tiles[game.drawnTile+1:])
+	if counts := countTileIds(view.remaining); !reflect.DeepEqual(counts, deck) {
+		t.Errorf("The view should have the remaining tiles %v, got %v", deck, counts)
+	}
+	for i := 1; i < len(view.remaining); i++ {
+		if view.remaining[i-1].id > view.remaining[i].id {
+			t.Fatalf("The remaining tiles should be sorted by id, not in the order of the deck")
+		}
+	}
+}
+
+func TestNewAgents(t *testing.T) {
+	agents, err := newAgents(strings.Split("random, first,greedy", ","))
+	if err != nil || len(agents) != 3 {
+		t.Fatalf("Expected three agents, got %v (%v)", agents, err)
+	}
+	if _, err := newAgents([]string{"nobody"}); err == nil {
+		t.Errorf("Expected an error for an unknown agent")
+	}
+	if _, err := newAgents(strings.Split(strings.Repeat("random,", MAX_PLAYERS), ",")); err == nil {
+		t.Errorf("Expected an error for more than %v agents", MAX_PLAYERS)
+	}
+}
+
+func TestNewAgentOptions(t *testing.T) {
+	agent, err := newAgent("mcts:iterations=5000:duration=2s:rollout=greedy")
+	expected := defaultMCTSConfig()
+	expected.iterations, expected.duration, expected.rollout = 5000, 2*time.Second, ROLLOUT_GREEDY
+	if mcts, ok := agent.(MCTSAgent); err != nil || !ok || mcts.config != expected {
+		t.Errorf("Expected the configured MCTS agent, got %v (%v)", agent, err)
+	}
+
+	agent, err = newAgent("expectimax:depth=3:opponent=min:pruning=false")
+	if e, ok := agent.(ExpectimaxAgent); err != nil || !ok || e.config.depth != 3 || e.config.opponent != OPPONENT_MIN || e.config.pruning {
+		t.Errorf("Expected the configured expectimax agent, got %v (%v)", agent, err)
+	}
+
+	for _, name := range []string{"mcts:iterations", "mcts:iterations=-1", "mcts:speed=1", "expectimax:opponent=nice", "greedy:depth=2"} {
+		if _, err := newAgent(name); err == nil {
+			t.Errorf("Expected an error for %v", name)
+		}
+	}
+}
+
+func TestRunGame(t *testing.T) {
+	game := generateInitialBoard(3, 0)
+	deckSize := len(game.tiles)
+
+	runGame(&game, []Agent{RandomAgent{}, FirstMoveAgent{}, GreedyAgent{}})
+
+	if len(game.tiles) != 0 {
+		t.Errorf("The deck should be empty, but has %v tiles", len(game.tiles))
+	}
+	if placed := len(game.board) - 1 + len(game.discardedTiles); placed != deckSize {
+		t.Errorf("%v tiles were placed or discarded instead of %v", placed, deckSize)
+	}
+	// One move per placed tile plus the final scoring
+	if len(game.lastMoves) != len(game.board) {
+		t.Errorf("Expected %v moves, got %v", len(game.board), len(game.lastMoves))
+	}
+	if expected := (len(game.board) - 1) % 3; game.currentPlayer != expected {
+		t.Errorf("Expected player %v to be next, got %v", expected, game.currentPlayer)
+	}
+}
+
+func TestDiscardUnplaceableTile(t *testing.T) {
+	_, tiles := getTiles()
+	var city, road Tile
+	for _, tile := range tiles {
+		switch tile.id {
+		case 2:
+			city = tile
+		case 21:
+			road = tile
+		}
+	}
+
+	// A road without any city can't be placed next to a tile with cities on all sides
+	game := generateInitialBoardFromTiles(2, city, []Tile{road, city})
+
+	tile, moves, ok := game.drawTile()
+	if !ok || tile.id != city.id || len(moves) == 0 {
+		t.Fatalf("Expected to draw the city tile, got %v (%v moves)", tile, len(moves))
+	}
+	if len(game.discardedTiles) != 1 || game.discardedTiles[0].id != road.id {
+		t.Errorf("Expected the road to be discarded, got %v", game.discardedTiles)
+	}
+
+	game.makeMove(moves[0])
+	if game.currentPlayer != 1 || game.drawnTile != -1 {
+		t.Errorf("Expected player 1 to be next without a drawn tile, got %v and %v", game.currentPlayer, game.drawnTile)
+	}
+	game.reverseLastMove()
+	if game.currentPlayer != 0 || game.drawnTile != 0 {
+		t.Errorf("Expected player 0 with the drawn tile after reversing, got %v and %v", game.currentPlayer, game.drawnTile)
+	}
+}
+
+func TestRunGameReproducible(t *testing.T) {
+	config := defaultMCTSConfig()
+	config.iterations = 10
+	config.rolloutDepth = 10
+	play := func(seed int64) GameState {
+		game := generateInitialBoard(3, seed)
+		runGame(&game, []Agent{RandomAgent{}, MCTSAgent{config}, GreedyAgent{}})
+		return game
+	}
+
+	game := play(5)
+	if diffs := game.Diff(play(5)); len(diffs) > 0 {
+		t.Errorf("The same seed should replay the same game: %v", diffs)
+	}
+	if game.Equal(play(6)) {
+		t.Errorf("Different seeds should play different games")
+	}
+}
+
+func TestSeedFlag(t *testing.T) {
+	var seed seedFlag
+	if seed.set {
+		t.Errorf("The seed should be unset by default")
+	}
+	if err := seed.Set("0"); err != nil || !seed.set || seed.value != 0 {
+		t.Errorf("The seed 0 should be set: %v (%v)", seed, err)
+	}
+	if err := seed.Set("random"); err == nil {
+		t.Errorf("Expected an error for an invalid seed")
+	}
+}
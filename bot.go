@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// External bots are processes that talk to the engine with lines of text over stdin and stdout, a bit like UCI
+// for chess engines. Moves are written in notation (see notation.go), positions as <x>,<y> and meeple sides by
+// their names. The engine sends:
+//
+//	carcassonne 1                  once at the start, the version of the protocol
+//	tileset <json>                 the tile set of the game in the format of tilesets/*.json, on one line
+//	game <players> <you>           number of players and the index of the bot's player
+//	reset                          the board is sent again, because moves were taken back. Forget the board
+//	tile <tile> r<rotation> @<x>,<y>    a tile on the board, after game and reset
+//	meeple <player> <x>,<y> <side>      a meeple on the board, after the tiles
+//	move <player> <move>           a move of any player, including the bot's own
+//	return <x>,<y>                 the meeple on the tile went back to its player, right after the move
+//	player <index> <score> <meeples>    score and meeples left of every player before each turn
+//	deck <tile>:<count> ...        the tiles left in the deck after the drawn one
+//	turn <tile> <tiles left>       the bot has to place the drawn tile, followed by
+//	moves <n>                      the number of legal moves and then one move per line
+//	error <message>                the answer was not understood or not legal. The bot answers again
+//	end <score> ...                the final scores
+//	quit                           the bot should exit
+//
+// The bot answers:
+//
+//	id name <name>                 optional, before ready
+//	ready                          after carcassonne, once the bot is ready to play
+//	play <move>                    after moves, the move of the bot
+//	info <text>                    anytime, is written to the log
+//
+// All other lines of the bot are logged and ignored. A bot that doesn't answer in time or exits is stopped and
+// the first legal move is played for it for the rest of the game. So is a bot, that doesn't find a legal move.
+const BOT_PROTOCOL_VERSION = 1
+
+// Time for a bot to exit after quit, before it is killed.
+const BOT_EXIT_TIMEOUT = time.Second
+
+type BotConfig struct {
+	// The program and its arguments
+	command []string
+	// Time to answer carcassonne with ready and to answer moves with play
+	startTimeout time.Duration
+	moveTimeout  time.Duration
+	// Number of answers per turn before the first legal move is played instead
+	attempts int
+	// Gets the stderr of the bot and info and errors about it
+	log io.Writer
+}
+
+func defaultBotConfig(command []string) BotConfig {
+	return BotConfig{command, 10 * time.Second, 10 * time.Second, 3, os.Stderr}
+}
+
+// Plays with an external bot process. The process is started with the first move of a game
+// and told to quit by EndGame(), so the same agent can play one game after another.
+type BotAgent struct {
+	config BotConfig
+	name   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	// Lines written by the bot. Closed when it exits
+	lines chan string
+	// The move lines sent to the bot, to only send the new ones next time
+	sent []string
+	// A bot that failed is not asked again until the game ends
+	failed bool
+	// Statistics over all games
+	illegalMoves int
+	timeouts     int
+	fallbacks    int
+}
+
+func newBotAgent(config BotConfig) *BotAgent {
+	name := ""
+	if len(config.command) > 0 {
+		name = config.command[0]
+	}
+	config.log = &lockedWriter{w: config.log}
+	return &BotAgent{config: config, name: name}
+}
+
+// The stderr of the bot is copied to the log in the background, while the agent writes to it too.
+type lockedWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.w.Write(p)
+}
+
+func (a *BotAgent) logf(format string, args ...interface{}) {
+	fmt.Fprintf(a.config.log, "bot %v: %v\n", a.name, fmt.Sprintf(format, args...))
+}
+
+func (a *BotAgent) send(format string, args ...interface{}) error {
+	_, err := fmt.Fprintf(a.stdin, format+"\n", args...)
+	return err
+}
+
+// Returns the next line of the bot. info lines are logged and skipped.
+func (a *BotAgent) readLine(deadline <-chan time.Time) (string, error) {
+	for {
+		select {
+		case line, ok := <-a.lines:
+			if !ok {
+				return "", fmt.Errorf("the bot exited")
+			}
+			if strings.HasPrefix(line, "info ") {
+				a.logf("%v", strings.TrimPrefix(line, "info "))
+				continue
+			}
+			return line, nil
+		case <-deadline:
+			a.timeouts++
+			return "", fmt.Errorf("the bot didn't answer in time")
+		}
+	}
+}
+
+// Starts the process and sends the game to it.
+func (a *BotAgent) start(game *GameState, player int) error {
+	if len(a.config.command) == 0 {
+		return fmt.Errorf("no bot command")
+	}
+	cmd := exec.Command(a.config.command[0], a.config.command[1:]...)
+	cmd.Stderr = a.config.log
+	// Children of the bot may keep its output open after it was killed
+	cmd.WaitDelay = BOT_EXIT_TIMEOUT
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	a.cmd = cmd
+	a.stdin = stdin
+	a.lines = make(chan string)
+	go func(lines chan string) {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- strings.TrimSpace(scanner.Text())
+		}
+		close(lines)
+	}(a.lines)
+
+	a.send("carcassonne %v", BOT_PROTOCOL_VERSION)
+	deadline := time.After(a.config.startTimeout)
+	for {
+		line, err := a.readLine(deadline)
+		if err != nil {
+			return err
+		}
+		if line == "ready" {
+			break
+		}
+		if strings.HasPrefix(line, "id name ") {
+			a.name = strings.TrimPrefix(line, "id name ")
+		} else {
+			a.logf("unexpected %q", line)
+		}
+	}
+
+	tileSet, err := json.Marshal(game.tileSet.file())
+	if err != nil {
+		return err
+	}
+	a.send("tileset %s", tileSet)
+	a.send("game %v %v", len(game.players), player)
+	a.sent = nil
+	return a.sendBoard(game)
+}
+
+// Sends every tile and meeple on the board.
+func (a *BotAgent) sendBoard(game *GameState) error {
+	positions := map[Pos]bool{}
+	for pos := range game.board {
+		positions[pos] = true
+	}
+	sorted := sortedPositions(positions)
+	for _, pos := range sorted {
+		tile := game.board[pos]
+		rotation, err := game.tileSet.rotation(tile)
+		if err != nil {
+			return err
+		}
+		a.send("tile %v r%v @%v,%v", game.tileSet.tileName(tile.id), rotation, pos.x, pos.y)
+	}
+	for _, pos := range sorted {
+		if m := game.board[pos].meeple; m.playerIndex != -1 {
+			a.send("meeple %v %v,%v %v", m.playerIndex, pos.x, pos.y, meepleSideName(m.sideIndex))
+		}
+	}
+	a.sent = a.moveLines(game)
+	return nil
+}
+
+func (a *BotAgent) moveLines(game *GameState) (lines []string) {
+	for _, m := range game.lastMoves {
+		if m.finalization {
+			continue
+		}
+		notation, _ := game.moveNotation(game.madeMove(m))
+		lines = append(lines, fmt.Sprintf("move %v %v", m.currentPlayer, notation))
+	}
+	return
+}
+
+// Sends the moves made since the last turn of the bot. If moves were taken back since, the whole board is sent again.
+func (a *BotAgent) sendMoves(game *GameState) error {
+	lines := a.moveLines(game)
+	same := len(lines) >= len(a.sent)
+	for i := 0; same && i < len(a.sent); i++ {
+		same = lines[i] == a.sent[i]
+	}
+	if !same {
+		a.send("reset")
+		return a.sendBoard(game)
+	}
+
+	// The finalization is always last, so the moves have the same index as their lines
+	for i := len(a.sent); i < len(lines); i++ {
+		a.send("%v", lines[i])
+		for _, r := range game.lastMoves[i].playerToBoardMeeple {
+			a.send("return %v,%v", r.pos.x, r.pos.y)
+		}
+	}
+	a.sent = lines
+	return nil
+}
+
+// Sends the turn and reads the answer. Gives up after the configured number of illegal answers.
+func (a *BotAgent) play(view GameView, tile Tile, moves []Move) (Move, error) {
+	if err := a.sendMoves(view.game); err != nil {
+		return Move{}, err
+	}
+	for _, p := range view.game.players {
+		a.send("player %v %v %v", p.index, p.score, p.meeples)
+	}
+
+	counts := countTileIds(view.remaining)
+	var ids []int
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	deck := "deck"
+	for _, id := range ids {
+		deck += fmt.Sprintf(" %v:%v", view.game.tileSet.tileName(id), counts[id])
+	}
+	a.send("%v", deck)
+
+	a.send("turn %v %v", view.game.tileSet.tileName(tile.id), len(view.remaining))
+	a.send("moves %v", len(moves))
+	for _, m := range moves {
+		notation, err := view.game.moveNotation(m)
+		if err != nil {
+			return Move{}, err
+		}
+		a.send("%v", notation)
+	}
+
+	deadline := time.After(a.config.moveTimeout)
+	for attempt := 0; attempt < a.config.attempts; {
+		line, err := a.readLine(deadline)
+		if err != nil {
+			return Move{}, err
+		}
+		if !strings.HasPrefix(line, "play ") {
+			a.logf("unexpected %q", line)
+			continue
+		}
+		attempt++
+		move, err := view.game.parseMove(strings.TrimPrefix(line, "play "))
+		if err == nil {
+			return move, nil
+		}
+		a.illegalMoves++
+		a.send("error %v", err)
+	}
+	return Move{}, fmt.Errorf("no legal move after %v attempts", a.config.attempts)
+}
+
+func (a *BotAgent) ChooseMove(view GameView, tile Tile, moves []Move) Move {
+	if !a.failed && a.cmd == nil {
+		if err := a.start(view.game, view.player.index); err != nil {
+			a.logf("can't start: %v, playing the first legal move instead", err)
+			a.stop(0)
+			a.failed = true
+		}
+	}
+	if !a.failed {
+		move, err := a.play(view, tile, moves)
+		if err == nil {
+			return move
+		}
+		a.logf("%v, playing the first legal move instead", err)
+		// The bot can't get back in sync, if it misses a move
+		a.stop(0)
+		a.failed = true
+	}
+	a.fallbacks++
+	return moves[0]
+}
+
+// Sends the final scores and lets the bot quit.
+func (a *BotAgent) EndGame(game *GameState) {
+	if a.cmd != nil {
+		scores := "end"
+		for _, p := range game.players {
+			scores += fmt.Sprintf(" %v", p.score)
+		}
+		a.send("%v", scores)
+		a.send("quit")
+		a.stop(BOT_EXIT_TIMEOUT)
+	}
+	a.failed = false
+}
+
+// Closes stdin and gives the bot up to wait to exit before it is killed.
+func (a *BotAgent) stop(wait time.Duration) {
+	if a.cmd == nil {
+		return
+	}
+	a.stdin.Close()
+	exited := make(chan bool)
+	go func(lines chan string) {
+		for range lines {
+		}
+		close(exited)
+	}(a.lines)
+	select {
+	case <-exited:
+	case <-time.After(wait):
+	}
+	a.cmd.Process.Kill()
+	a.cmd.Wait()
+	a.cmd = nil
+	a.lines = nil
+}
@@ -0,0 +1,64 @@
+#!/usr/bin/env python3
+# A minimal external bot, see bot.go for the protocol. Play against it with
+#   go run . -agents "greedy,bot:python3 bot.py"
+# It keeps track of the board and the scores and plays a random legal move.
+import json
+import random
+import sys
+
+
+def send(line):
+    print(line, flush=True)
+
+
+def main():
+    tile_set = None
+    board = {}
+    meeples = {}
+    scores = []
+    me = -1
+
+    lines = iter(sys.stdin.readline, "")
+    for line in lines:
+        words = line.split()
+        if not words:
+            continue
+        command = words[0]
+
+        if command == "carcassonne":
+            send("id name random.py")
+            send("ready")
+        elif command == "tileset":
+            tile_set = json.loads(line[len("tileset "):])
+        elif command == "game":
+            scores = [0] * int(words[1])
+            me = int(words[2])
+        elif command == "reset":
+            board.clear()
+            meeples.clear()
+        elif command == "tile":
+            # tile <tile> r<rotation> @<x>,<y>
+            board[words[3][1:]] = (words[1], int(words[2][1:]))
+        elif command == "meeple":
+            meeples[words[2]] = (int(words[1]), words[3])
+        elif command == "move":
+            # move <player> <tile> r<rotation> @<x>,<y> [M:<side>]
+            board[words[4][1:]] = (words[2], int(words[3][1:]))
+            if len(words) == 6:
+                meeples[words[4][1:]] = (int(words[1]), words[5][2:])
+        elif command == "return":
+            meeples.pop(words[1], None)
+        elif command == "player":
+            scores[int(words[1])] = int(words[2])
+        elif command == "moves":
+            moves = [next(lines).strip() for _ in range(int(words[1]))]
+            send("play " + random.choice(moves))
+        elif command == "error":
+            send("info the engine said: " + line[len("error "):].strip())
+            send("play " + random.choice(moves))
+        elif command == "quit":
+            return
+
+
+if __name__ == "__main__":
+    main()
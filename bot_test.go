@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Not a real test: the test binary runs itself with CARCASSONNE_TEST_BOT set to act as a bot.
+// The argument after -- decides how it plays.
+func TestBotHelperProcess(t *testing.T) {
+	if os.Getenv("CARCASSONNE_TEST_BOT") == "" {
+		return
+	}
+	behavior := os.Args[len(os.Args)-1]
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		words := strings.Fields(in.Text())
+		switch {
+		case len(words) == 0:
+		case words[0] == "carcassonne" && behavior != "silent":
+			fmt.Println("id name", behavior)
+			fmt.Println("info starting")
+			fmt.Println("ready")
+		case words[0] == "moves":
+			n, _ := strconv.Atoi(words[1])
+			var moves []string
+			for i := 0; i < n && in.Scan(); i++ {
+				moves = append(moves, in.Text())
+			}
+			switch behavior {
+			case "first":
+				fmt.Println("play", moves[0])
+			case "last":
+				fmt.Println("something else")
+				fmt.Println("play", moves[len(moves)-1])
+			case "illegal":
+				fmt.Println("play nonsense")
+			}
+		case words[0] == "error" && behavior == "illegal":
+			fmt.Println("play", "A r0 @100,100")
+		case words[0] == "quit":
+			os.Exit(0)
+		}
+	}
+	os.Exit(0)
+}
+
+func testBotConfig(t *testing.T, behavior string, log io.Writer) BotConfig {
+	t.Setenv("CARCASSONNE_TEST_BOT", "1")
+	config := defaultBotConfig([]string{os.Args[0], "-test.run=^TestBotHelperProcess$", "--", behavior})
+	config.startTimeout = time.Second
+	config.moveTimeout = time.Second
+	config.log = log
+	return config
+}
+
+type LastMoveAgent struct{}
+
+func (a LastMoveAgent) ChooseMove(view GameView, tile Tile, moves []Move) Move {
+	return moves[len(moves)-1]
+}
+
+func TestBotAgent(t *testing.T) {
+	var log bytes.Buffer
+	bot := newBotAgent(testBotConfig(t, "last", &log))
+
+	// The same agent plays two games, one process each
+	for i := 0; i < 2; i++ {
+		game := generateInitialBoard(2, 3)
+		runGame(&game, []Agent{bot, FirstMoveAgent{}})
+
+		expected := generateInitialBoard(2, 3)
+		runGame(&expected, []Agent{LastMoveAgent{}, FirstMoveAgent{}})
+		if fmt.Sprint(game.players) != fmt.Sprint(expected.players) || len(game.board) != len(expected.board) {
+			t.Errorf("The bot didn't play the last moves: %v instead of %v", game.players, expected.players)
+		}
+	}
+
+	if bot.cmd != nil {
+		t.Errorf("The bot should have quit")
+	}
+	if bot.name != "last" || bot.illegalMoves != 0 || bot.timeouts != 0 || bot.fallbacks != 0 {
+		t.Errorf("Unexpected bot %v with %v illegal moves, %v timeouts and %v fallbacks", bot.name, bot.illegalMoves, bot.timeouts, bot.fallbacks)
+	}
+	if !strings.Contains(log.String(), "bot last: starting") || !strings.Contains(log.String(), "unexpected \"something else\"") {
+		t.Errorf("The info and unknown lines should be logged:\n%v", log.String())
+	}
+}
+
+func TestBotAgentFailures(t *testing.T) {
+	tests := []struct {
+		behavior                         string
+		illegalMoves, timeouts, failures int
+	}{
+		{"illegal", 3, 0, 1},
+		{"slow", 0, 1, 1},
+		{"silent", 0, 1, 1},
+	}
+	for _, test := range tests {
+		var log bytes.Buffer
+		config := testBotConfig(t, test.behavior, &log)
+		config.startTimeout = 200 * time.Millisecond
+		config.moveTimeout = 200 * time.Millisecond
+		bot := newBotAgent(config)
+
+		// After the first failure the bot isn't asked anymore
+		game := generateInitialBoard(2, 3)
+		runGame(&game, []Agent{bot, FirstMoveAgent{}})
+		expected := generateInitialBoard(2, 3)
+		runGame(&expected, []Agent{FirstMoveAgent{}, FirstMoveAgent{}})
+
+		if fmt.Sprint(game.players) != fmt.Sprint(expected.players) {
+			t.Errorf("%v: expected the first moves to be played, got %v instead of %v", test.behavior, game.players, expected.players)
+		}
+		if bot.illegalMoves != test.illegalMoves || bot.timeouts != test.timeouts {
+			t.Errorf("%v: expected %v illegal moves and %v timeouts, got %v and %v", test.behavior, test.illegalMoves, test.timeouts, bot.illegalMoves, bot.timeouts)
+		}
+		// The bot is the first player, so it made every other move, starting with the first
+		if moves := len(expected.board) - 1; bot.fallbacks != (moves+1)/2 {
+			t.Errorf("%v: expected a fallback for every move, got %v", test.behavior, bot.fallbacks)
+		}
+		if bot.cmd != nil || !strings.Contains(log.String(), "playing the first legal move instead") {
+			t.Errorf("%v: the bot should have been stopped:\n%v", test.behavior, log.String())
+		}
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func TestBotSendMoves(t *testing.T) {
+	var sent bytes.Buffer
+	bot := &BotAgent{stdin: nopWriteCloser{&sent}}
+	game := generateInitialBoard(2, 3)
+
+	bot.sendBoard(&game)
+	if sent.String() != "tile D r0 @0,0\n" {
+		t.Errorf("Expected the start tile, got %q", sent.String())
+	}
+
+	var notations []string
+	for i := 0; i < 6; i++ {
+		_, moves, _ := game.drawTile()
+		game.makeMove(moves[len(moves)-1])
+		notation, _ := game.moveNotation(moves[len(moves)-1])
+		notations = append(notations, fmt.Sprintf("move %v %v", i%2, notation))
+	}
+	sent.Reset()
+	bot.sendMoves(&game)
+	if moves := strings.Join(notations, "\n") + "\n"; sent.String() != moves {
+		t.Errorf("Expected the moves\n%v\ngot\n%v", moves, sent.String())
+	}
+
+	// Nothing new
+	sent.Reset()
+	bot.sendMoves(&game)
+	if sent.Len() != 0 {
+		t.Errorf("Expected nothing, got %q", sent.String())
+	}
+
+	// A different move after undo sends the board again
+	game.reverseLastMove()
+	_, moves, _ := game.drawTile()
+	game.makeMove(moves[0])
+	sent.Reset()
+	bot.sendMoves(&game)
+	if lines := strings.Split(strings.TrimSpace(sent.String()), "\n"); lines[0] != "reset" || len(lines) < 1+len(game.board) {
+		t.Errorf("Expected the board again, got\n%v", sent.String())
+	}
+}
+
+func TestNewBotAgent(t *testing.T) {
+	agents, err := newAgents([]string{"bot: python3  bot.py --fast"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bot, ok := agents[0].(*BotAgent); !ok || fmt.Sprint(bot.config.command) != "[python3 bot.py --fast]" {
+		t.Errorf("Expected a bot agent, got %+v", agents[0])
+	}
+	if _, err := newAgent("bot:"); err == nil {
+		t.Errorf("Expected an error without a command")
+	}
+}
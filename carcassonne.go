@@ -1,8 +1,16 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"math/rand"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
 
 	//"math/rand"
 	"strconv"
@@ -22,12 +30,14 @@ const (
 	SIDE_RIGHT  = 2
 	SIDE_UP     = 3
 	SIDE_CENTER = 4
+	// Farmers are placed on one of the 8 field segments: SIDE_FIELD + segment. See fields.go
+	SIDE_FIELD = 5
 )
 
 var (
 	// The index of this array is also the side it extends to! So 0 == left, 1 == down, 2 == right, 3 == up
 	g_sides    []Pos = []Pos{Pos{-1, 0}, Pos{0, 1}, Pos{1, 0}, Pos{0, -1}}
-	g_allSides []Pos = []Pos{Pos{-1, 0}, Pos{0, 1}, Pos{1, 0}, Pos{0, -1}, Pos{-1, -1}, Pos{1, -1}, Pos{1, -1}, Pos{1, 1}}
+	g_allSides []Pos = []Pos{Pos{-1, 0}, Pos{0, 1}, Pos{1, 0}, Pos{0, -1}, Pos{-1, -1}, Pos{1, -1}, Pos{-1, 1}, Pos{1, 1}}
 	// Even though those are not positions per se, it's two ints which is exactly what we need here.
 	g_connectionIndices = []Pos{Pos{0, 1}, Pos{0, 2}, Pos{0, 3}, Pos{1, 2}, Pos{1, 3}, Pos{2, 3}}
 )
@@ -70,12 +80,53 @@ type Visited struct {
 	side int
 }
 
+// The board, optionally with one more tile on it that is not written into the board map.
+// The move generation uses it to look at a tile before it is placed.
+type boardView struct {
+	board   map[Pos]Tile
+	pos     Pos
+	tile    Tile
+	hasTile bool
+}
+
+func viewBoard(board map[Pos]Tile) boardView {
+	return boardView{board: board}
+}
+
+func viewBoardWith(board map[Pos]Tile, pos Pos, tile Tile) boardView {
+	return boardView{board, pos, tile, true}
+}
+
+func (b boardView) get(pos Pos) (Tile, bool) {
+	if b.hasTile && pos == b.pos {
+		return b.tile, true
+	}
+	tile, ok := b.board[pos]
+	return tile, ok
+}
+
 type GameState struct {
 	board          map[Pos]Tile
 	tiles          []Tile
 	players        []Player
 	openPlacements map[Pos]bool
 	lastMoves      []ReverseMove
+	// Roads and cities on the board. Use structureRegistry() to access it!
+	structures *structureRegistry
+	// Index of the player whose turn it is
+	currentPlayer int
+	// Index into tiles of the tile the current player has drawn. -1 if no tile was drawn yet
+	drawnTile int
+	// Tiles that could not be placed anywhere when they were drawn. They are out of the game
+	discardedTiles []Tile
+	// All randomness of the game (shuffling, agents breaking ties, ...) comes from rng, which is
+	// seeded with seed. The same seed and the same agents replay the same game.
+	seed int64
+	rng  *rand.Rand
+	// The source of rng. Counts the random numbers, so a saved game can go on with the same ones
+	rngSource *countingSource
+	// The tile types of the game, the tile id is the index. Used to name and rotate tiles
+	tileSet TileSet
 }
 
 type ReverseMeeplePlacement struct {
@@ -98,12 +149,21 @@ type ReverseMove struct {
 	boardToPlayerMeeple ReverseMeeplePlacement
 	// Placed tile on the board that needs to be removed again. This one needs to
 	// be added to openPlacements as well!
-	// The tile also needs to be placed back onto the tiles-list of the game-state!
 	removeTileFromBoard Pos
+	// The drawn tile was taken from this index of the tiles-list. It needs to be placed back! -1 if no tile was drawn
+	deckIndex int
+	deckTile  Tile
 	// Those positions were added to the openPlacements set. They need to be removed!
 	addedNewOpenPlacements []Pos
 	// Final points that were awarded to a player
 	awardedPoints []ReversePlayerPoints
+	// The end of the game was scored. No tile was placed, so there is nothing to remove from the board!
+	finalization bool
+	// All changes to the structure registry after this mark need to be rolled back
+	structures structureMark
+	// Turn and drawn tile before the move
+	currentPlayer int
+	drawnTile     int
 }
 
 func (r ReverseMeeplePlacement) String() string {
@@ -195,27 +255,38 @@ func (t Tile) hasConnectionAtSide(i int) bool {
 	return false
 }
 
+// 0x7BDE == 0b0111101111011110 --> All sides are connected!
 func (t Tile) allSidesAreConnected() bool {
-	return t.connections == 0x7B2E
+	return t.connections == 0x7BDE
 }
 
 func (t Tile) hasNoConnections() bool {
 	return t.connections == 0x0
 }
 
-func drawColor(m Meeple, drawColor bool, s string) {
+func drawColor(w io.Writer, m Meeple, drawColor bool, s string) {
 	cStart, cEnd := m.colorCodeStartEnd()
 
 	if drawColor && m.playerIndex != -1 {
-		fmt.Printf("%v%v", cStart, s)
+		fmt.Fprintf(w, "%v%v", cStart, s)
 	} else {
-		fmt.Printf("%v", s)
+		fmt.Fprintf(w, "%v", s)
 	}
 
-	fmt.Printf("%v", cEnd)
+	fmt.Fprintf(w, "%v", cEnd)
 }
 
 func drawField(board map[Pos]Tile) {
+	writeField(os.Stdout, board)
+}
+
+// Draws the board. The grass of the highlighted tiles is drawn with * instead of ~.
+func writeField(w io.Writer, board map[Pos]Tile, highlight ...Pos) {
+	writeLabeledField(w, board, nil, highlight...)
+}
+
+// Draws the board like writeField() and writes the labels (up to three characters) into empty positions.
+func writeLabeledField(w io.Writer, board map[Pos]Tile, labels map[Pos]string, highlight ...Pos) {
 
 	minX, maxX := 0, 0
 	minY, maxY := 0, 0
@@ -225,60 +296,99 @@ func drawField(board map[Pos]Tile) {
 		maxX = max(maxX, k.x)
 		maxY = max(maxY, k.y)
 	}
+	for k := range labels {
+		minX = min(minX, k.x)
+		minY = min(minY, k.y)
+		maxX = max(maxX, k.x)
+		maxY = max(maxY, k.y)
+	}
 
 	for y := minY; y <= maxY; y++ {
 		for row := 0; row < 3; row++ {
 			for x := minX; x <= maxX; x++ {
 				if t, ok := board[Pos{x, y}]; ok {
 					filler := "~"
+					for _, h := range highlight {
+						if h == (Pos{x, y}) {
+							filler = "*"
+						}
+					}
 
 					switch row {
 					case 0:
 						if t.emblem {
-							fmt.Printf("#")
+							fmt.Fprintf(w, "#")
 						} else {
-							fmt.Printf("%v", filler)
+							fmt.Fprintf(w, "%v", filler)
 						}
-						fmt.Printf("%v", filler)
-						drawColor(t.meeple, t.meeple.sideIndex == SIDE_UP, fmt.Sprintf("%v", t.sides[SIDE_UP].StringShort()))
-						fmt.Printf("%v%v", filler, filler)
+						drawColor(w, t.meeple, t.meeple.onFieldSegment(7, 0), filler)
+						drawColor(w, t.meeple, t.meeple.sideIndex == SIDE_UP, fmt.Sprintf("%v", t.sides[SIDE_UP].StringShort()))
+						drawColor(w, t.meeple, t.meeple.onFieldSegment(5, 6), filler)
+						fmt.Fprintf(w, "%v", filler)
 					case 1:
-						drawColor(t.meeple, t.meeple.sideIndex == SIDE_LEFT, fmt.Sprintf("%v", t.sides[SIDE_LEFT].StringShort()))
+						drawColor(w, t.meeple, t.meeple.sideIndex == SIDE_LEFT, fmt.Sprintf("%v", t.sides[SIDE_LEFT].StringShort()))
 
 						if t.hasConnectionAtSide(SIDE_LEFT) {
-							fmt.Printf("%v", t.sides[SIDE_LEFT].StringShort())
+							fmt.Fprintf(w, "%v", t.sides[SIDE_LEFT].StringShort())
 						} else {
-							fmt.Printf("%v", filler)
+							fmt.Fprintf(w, "%v", filler)
 						}
 						if t.cloister {
-							drawColor(t.meeple, t.meeple.sideIndex == SIDE_CENTER, "Ħ")
+							drawColor(w, t.meeple, t.meeple.sideIndex == SIDE_CENTER, "Ħ")
 						} else {
 							if !t.hasNoConnections() {
-								fmt.Printf("+")
+								fmt.Fprintf(w, "+")
 							} else {
-								fmt.Printf("%v", filler)
+								fmt.Fprintf(w, "%v", filler)
 							}
 						}
 						if t.hasConnectionAtSide(SIDE_RIGHT) {
-							fmt.Printf("%v", t.sides[SIDE_RIGHT].StringShort())
+							fmt.Fprintf(w, "%v", t.sides[SIDE_RIGHT].StringShort())
 						} else {
-							fmt.Printf("%v", filler)
+							fmt.Fprintf(w, "%v", filler)
 						}
-						drawColor(t.meeple, t.meeple.sideIndex == SIDE_RIGHT, t.sides[SIDE_RIGHT].StringShort())
+						drawColor(w, t.meeple, t.meeple.sideIndex == SIDE_RIGHT, t.sides[SIDE_RIGHT].StringShort())
 					case 2:
-						fmt.Printf("%v%v", filler, filler)
-						drawColor(t.meeple, t.meeple.sideIndex == SIDE_DOWN, fmt.Sprintf("%v", t.sides[SIDE_DOWN].StringShort()))
-						fmt.Printf("%v%v", filler, filler)
+						fmt.Fprintf(w, "%v", filler)
+						drawColor(w, t.meeple, t.meeple.onFieldSegment(1, 2), filler)
+						drawColor(w, t.meeple, t.meeple.sideIndex == SIDE_DOWN, fmt.Sprintf("%v", t.sides[SIDE_DOWN].StringShort()))
+						drawColor(w, t.meeple, t.meeple.onFieldSegment(3, 4), filler)
+						fmt.Fprintf(w, "%v", filler)
 					}
 
+				} else if label, ok := labels[Pos{x, y}]; ok && row == 1 {
+					fmt.Fprintf(w, "[%3v]", label)
 				} else {
-					fmt.Printf("     ")
+					fmt.Fprintf(w, "     ")
 				}
 			}
-			fmt.Println("")
+			fmt.Fprintln(w, "")
 		}
 	}
-	fmt.Println("")
+	fmt.Fprintln(w, "")
+}
+
+func sortedPositions(positions map[Pos]bool) []Pos {
+	sorted := make([]Pos, 0, len(positions))
+	for p := range positions {
+		sorted = append(sorted, p)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].y != sorted[j].y {
+			return sorted[i].y < sorted[j].y
+		}
+		return sorted[i].x < sorted[j].x
+	})
+	return sorted
+}
+
+func isOneOf(i int, list []int) bool {
+	for _, l := range list {
+		if l == i {
+			return true
+		}
+	}
+	return false
 }
 
 func add(p, p2 Pos) Pos {
@@ -293,28 +403,24 @@ func multiplyTile(tiles *[]Tile, t Tile, count int) {
 	}
 }
 
+// Returns the start tile and the 71 remaining tiles of the base game (72 tiles in total), ordered by id.
+// The tile ids follow the tile names of the base game: 0 == A, 1 == B, ..., 23 == X.
+// The start tile is just another D tile.
 func getTiles() (Tile, []Tile) {
+	return baseTileSet().deck(nil)
+}
 
+// Returns the 12 extra tiles (WinTierA - WinTierL in tiles.py). They are not part of the
+// 72 tiles of the base game and are not shuffled. Their ids continue after X, so 24 == TierA.
+func getExtraTiles() []Tile {
+	ts := baseTileSet()
 	var tiles []Tile
-	var id int
-
-	multiplyTile(&tiles, Tile{id, [4]Area{AREA_GRASS, AREA_ROAD, AREA_GRASS, AREA_GRASS}, true, false, 0, Meeple{-1, -1}}, 2)
-	id++
-	multiplyTile(&tiles, Tile{id, [4]Area{AREA_GRASS, AREA_GRASS, AREA_GRASS, AREA_GRASS}, true, false, 0, Meeple{-1, -1}}, 4)
-	id++
-	conn := connectionsToUint16([]Pos{Pos{0, 1}, Pos{0, 2}, Pos{0, 3}, Pos{1, 2}, Pos{1, 3}, Pos{2, 3}})
-	multiplyTile(&tiles, Tile{id, [4]Area{AREA_CITY, AREA_CITY, AREA_CITY, AREA_CITY}, false, true, conn, Meeple{-1, -1}}, 1)
-	id++
-	conn = connectionsToUint16([]Pos{Pos{0, 2}})
-	multiplyTile(&tiles, Tile{id, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_CITY}, false, false, conn, Meeple{-1, -1}}, 3)
-	id++
-
-	conn = connectionsToUint16([]Pos{Pos{0, 2}})
-	startTile := Tile{id, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_CITY}, false, false, conn, Meeple{-1, -1}}
-
-	rand.Shuffle(len(tiles), func(i, j int) { tiles[i], tiles[j] = tiles[j], tiles[i] })
-
-	return startTile, tiles
+	for i, t := range ts.types {
+		if ts.expansions[i] == "tier" {
+			multiplyTile(&tiles, t, ts.counts[i])
+		}
+	}
+	return tiles
 }
 
 func rotateTile(tile Tile) Tile {
@@ -325,22 +431,25 @@ func rotateTile(tile Tile) Tile {
 	}
 	tile.sides[0] = last
 
-	// 0x7B2E == 0b0111101100101110 --> All sides are connected!
-	if tile.connections != 0 && tile.connections != 0x7B2E {
+	// Rows and columns both have to move one side further, just like the sides themselves.
+	if tile.connections != 0 && !tile.allSidesAreConnected() {
 		a := tile.connections & 0xf
 		b := (tile.connections >> 4) & 0xf
 		c := (tile.connections >> 8) & 0xf
 		d := (tile.connections >> 12) & 0xf
 
-		a = (a>>1 | a<<3) & 0xf
-		b = (b>>1 | b<<3) & 0xf
-		c = (c>>1 | c<<3) & 0xf
-		d = (d>>1 | d<<3) & 0xf
+		a = (a<<1 | a>>3) & 0xf
+		b = (b<<1 | b>>3) & 0xf
+		c = (c<<1 | c>>3) & 0xf
+		d = (d<<1 | d>>3) & 0xf
 
-		tile.connections = (a << 12) | (d << 8) | (c << 4) | b
+		tile.connections = (c << 12) | (b << 8) | (a << 4) | d
 	}
 
-	if tile.meeple.sideIndex != SIDE_CENTER {
+	if tile.meeple.sideIndex >= SIDE_FIELD {
+		// Every side has two field segments
+		tile.meeple.sideIndex = SIDE_FIELD + (tile.meeple.sideIndex-SIDE_FIELD+2)%FIELD_SEGMENTS
+	} else if tile.meeple.sideIndex != SIDE_CENTER {
 		tile.meeple.sideIndex = (tile.meeple.sideIndex + 1) % 4
 	}
 	return tile
@@ -362,34 +471,57 @@ func placementPossible(board map[Pos]Tile, tile Tile, pos Pos) bool {
 	return true
 }
 
-func generatePossibleMoves(board map[Pos]Tile, tiles []Tile, openPlacements map[Pos]bool, player Player) (moves []Move) {
-
-	for place := range openPlacements {
+// Returns all moves of the player with the tiles at the open placements. playerCount is the number of players
+// in the game, meeples are counted per player to find occupied structures.
+func generatePossibleMoves(board map[Pos]Tile, tiles []Tile, openPlacements map[Pos]bool, player Player, playerCount int) (moves []Move) {
+
+	// At some point - implement a statistic (remaining tile_type * tile_count / all_tile_count or something)
+	// Symmetric tiles look the same in more than one rotation (like U at r0 and r2). Those would be the same moves,
+	// so only the first of the equal rotations is used.
+	var rotated []Tile
+	alreadyPlaced := make(map[int]bool)
+	for _, t := range tiles {
+		if _, ok := alreadyPlaced[t.id]; ok {
+			continue
+		}
+		alreadyPlaced[t.id] = true
 
-		// At some point - implement a statistic (remaining tile_type * tile_count / all_tile_count or something)
-		alreadyPlaced := make(map[int]bool)
-		for _, t := range tiles {
-			if _, ok := alreadyPlaced[t.id]; ok {
-				continue
+		first := len(rotated)
+		for rot := 0; rot < 4; rot++ {
+			if rot > 0 {
+				t = rotateTile(t)
 			}
-			alreadyPlaced[t.id] = true
+			t.meeple = Meeple{-1, -1}
+			if !containsTile(rotated[first:], t) {
+				rotated = append(rotated, t)
+			}
+		}
+	}
 
-			for rot := 0; rot < 4; rot++ {
-				if rot > 0 {
-					t = rotateTile(t)
-				}
-				if placementPossible(board, t, place) {
-					moves = append(moves, Move{t, place})
-
-					if player.meeples > 0 {
-						for side := 0; side < 4; side++ {
-							if t.sides[side] != AREA_GRASS {
-								t.meeple = Meeple{side, player.index}
-								moves = append(moves, Move{t, place})
-							}
+	// Sorted, so the order of the moves doesn't depend on the map iteration order
+	for _, place := range sortedPositions(openPlacements) {
+		for _, t := range rotated {
+			if placementPossible(board, t, place) {
+				moves = append(moves, Move{t, place})
+
+				if player.meeples > 0 {
+					// The structures are checked as if the tile was placed, without writing it into the board
+					view := viewBoardWith(board, place, t)
+
+					for side := 0; side < 4; side++ {
+						if t.sides[side] != AREA_GRASS && !structureOccupied(view, place, side, playerCount) {
+							t.meeple = Meeple{side, player.index}
+							moves = append(moves, Move{t, place})
 						}
-						if t.cloister {
-							t.meeple = Meeple{SIDE_CENTER, player.index}
+					}
+					if t.cloister {
+						t.meeple = Meeple{SIDE_CENTER, player.index}
+						moves = append(moves, Move{t, place})
+					}
+					// One farmer move per field of the tile
+					for segment, field := range t.fieldSegments() {
+						if segment == field && !fieldOccupied(view, place, segment, playerCount) {
+							t.meeple = Meeple{SIDE_FIELD + segment, player.index}
 							moves = append(moves, Move{t, place})
 						}
 					}
@@ -401,8 +533,55 @@ func generatePossibleMoves(board map[Pos]Tile, tiles []Tile, openPlacements map[
 	return
 }
 
+func containsTile(tiles []Tile, tile Tile) bool {
+	for _, t := range tiles {
+		if t == tile {
+			return true
+		}
+	}
+	return false
+}
+
+// A meeple can only be placed on a road or city, if there is no other meeple on the whole structure yet.
+// The tile at pos must already be on the board or the view.
+func structureOccupied(board boardView, pos Pos, side int, playerCount int) bool {
+	searched := map[Visited]bool{}
+	meeples := make([]int, playerCount, playerCount)
+	calcRecursivePoints(board, pos, side, &searched, &meeples)
+
+	for _, count := range meeples {
+		if count > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Same as structureOccupied() for farmers on a field.
+func fieldOccupied(board boardView, pos Pos, segment int, playerCount int) bool {
+	farmers := make([]int, playerCount, playerCount)
+	fieldSegmentsOnBoard(board, pos, segment, map[Visited]bool{}, farmers)
+
+	for _, count := range farmers {
+		if count > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func placeTile(game *GameState, tile Tile, pos Pos, revMove *ReverseMove) {
+	structures := game.structureRegistry()
+	revMove.structures = structures.mark()
+
+	// A replaced tile can't be taken out of the registry, so it is built from scratch again
+	_, replaced := game.board[pos]
 	game.board[pos] = tile
+	if replaced {
+		game.invalidateStructures()
+	} else {
+		structures.addTile(pos, tile)
+	}
 	delete(game.openPlacements, pos)
 	if tile.meeple.playerIndex != -1 {
 		game.players[tile.meeple.playerIndex].meeples -= 1
@@ -414,7 +593,8 @@ func placeTile(game *GameState, tile Tile, pos Pos, revMove *ReverseMove) {
 	revMove.removeTileFromBoard = pos
 
 	for _, s := range g_sides {
-		if _, ok := game.board[add(pos, s)]; !ok {
+		// Positions that were open before must stay open when the move is reversed
+		if _, ok := game.board[add(pos, s)]; !ok && !game.openPlacements[add(pos, s)] {
 			game.openPlacements[add(pos, s)] = true
 			revMove.addedNewOpenPlacements = append(revMove.addedNewOpenPlacements, add(pos, s))
 		}
@@ -424,14 +604,14 @@ func placeTile(game *GameState, tile Tile, pos Pos, revMove *ReverseMove) {
 
 // Returns:
 // Score, positions_with_meeple_on_them, is_closed
-func calcRecursivePoints(board map[Pos]Tile, pos Pos, side int, searched *map[Pos]bool, meeples *[]int) (int, []Pos, bool) {
+func calcRecursivePoints(board boardView, pos Pos, side int, searched *map[Visited]bool, meeples *[]int) (int, []Pos, bool) {
 
-	// We already visited this tile
-	if _, ok := (*searched)[pos]; ok {
+	// We already visited this part of the tile
+	if _, ok := (*searched)[Visited{pos, side}]; ok {
 		return 0, nil, true
 	}
 
-	tile, ok := board[pos]
+	tile, ok := board.get(pos)
 	// If tile is not even on the board. Do we need this check?
 	if !ok {
 		return 0, nil, false
@@ -441,15 +621,29 @@ func calcRecursivePoints(board map[Pos]Tile, pos Pos, side int, searched *map[Po
 		return 0, nil, false
 	}
 
-	(*searched)[pos] = true
+	// A structure can pass through the same tile more than once, for example a road through both
+	// road pairs of TierI. So the sides are searched, but every tile only counts once.
+	newTile := true
+	for s := 0; s < 4; s++ {
+		if (*searched)[Visited{pos, s}] {
+			newTile = false
+		}
+	}
+	for s := 0; s < 4; s++ {
+		if tile.isConnected(side, s) {
+			(*searched)[Visited{pos, s}] = true
+		}
+	}
 
 	score, positions, closed := calcRecursivePoints(board, add(pos, g_sides[side]), (side+2)%4, searched, meeples)
 
 	// This tile also counts
-	score += 1
-	// An extra point, if we are building a city which has an emblem on it!
-	if tile.emblem && tile.sides[side] == AREA_CITY {
+	if newTile {
 		score += 1
+		// An extra point, if we are building a city which has an emblem on it!
+		if tile.emblem && tile.sides[side] == AREA_CITY {
+			score += 1
+		}
 	}
 
 	if tile.meeple.playerIndex != -1 && tile.meeple.sideIndex == side {
@@ -507,6 +701,7 @@ func (game *GameState) cleanupUsedMeeplesFromBoard(positions []Pos, revMove *Rev
 
 		// before we overwrite tile.meeple
 		revMove.playerToBoardMeeple = append(revMove.playerToBoardMeeple, ReverseMeeplePlacement{t.meeple.playerIndex, p, t.meeple.sideIndex})
+		game.structureRegistry().addMeeple(p, t.meeple, -1)
 
 		game.players[t.meeple.playerIndex].meeples += 1
 		t.meeple = Meeple{-1, -1}
@@ -545,40 +740,34 @@ func (game *GameState) updateFinalPoints(pos Pos, revMove *ReverseMove) {
 		}
 	}
 
-	for side := 0; side < 4; side++ {
-		if game.board[pos].hasConnectionAtSide(side) {
+	structures := game.structureRegistry()
 
-			// We always skip one side of a connection, so we only search each possible way once!
-			ok := false
-			for _, c := range g_connectionIndices {
-				if c.x == side {
-					ok = true
-				}
-			}
-			if !ok {
-				continue
-			}
+	// Connected sides belong to the same structure. We only want to score each of them once!
+	scored := [4]int{-1, -1, -1, -1}
+	for side := 0; side < 4; side++ {
+		root, ok := structures.structure(pos, side)
+		if !ok || isOneOf(root, scored[:side]) {
+			continue
 		}
+		scored[side] = root
 
-		searched := map[Pos]bool{}
-		meeples := make([]int, len(game.players), len(game.players))
-		score, positions, closed := calcRecursivePoints(game.board, pos, side, &searched, &meeples)
-
-		if bestPlayer := getBestPlayerIndex(meeples); closed && bestPlayer != -1 {
+		structure := structures.nodes[root]
+		if structure.openEdges != 0 || !structure.hasMeeples() {
+			continue
+		}
 
-			// Closed cities count twice!
-			if t, ok := game.board[pos]; ok && t.sides[side] == AREA_CITY {
-				score *= 2
-			}
+		// Closed cities count twice!
+		score := structure.points()
+		meeples := structure.meeples[:len(game.players)]
+		bestPlayer := getBestPlayerIndex(meeples)
 
-			for playerIndex, count := range meeples {
-				if count == meeples[bestPlayer] {
-					game.players[playerIndex].score += score
-					revMove.awardedPoints = append(revMove.awardedPoints, ReversePlayerPoints{playerIndex, score})
-				}
+		for playerIndex, count := range meeples {
+			if count == meeples[bestPlayer] {
+				game.players[playerIndex].score += score
+				revMove.awardedPoints = append(revMove.awardedPoints, ReversePlayerPoints{playerIndex, score})
 			}
 		}
-		game.cleanupUsedMeeplesFromBoard(positions, revMove)
+		game.cleanupUsedMeeplesFromBoard(structureMeeplePositions(game.board, pos, side), revMove)
 	}
 }
 
@@ -593,27 +782,18 @@ func getMeeplePositions(board map[Pos]Tile) map[Pos]bool {
 	return meeplePositions
 }
 
-// Returns any possibly random key from the map. Order doesn't matter here!
-func getKey(positions map[Pos]bool) Pos {
-	for k := range positions {
-		return k
-	}
-	return Pos{0, 0}
-}
-
 // Calculates the immediate points, that are not yet finalized. So unfinished roads,
 // unfinished cities or unfinished cloisters
-func (game GameState) updateImmediatePoints(playerScores *[]int) {
+func (game *GameState) updateImmediatePoints(playerScores *[]int) {
 
-	// Initial set!
-	meeplePositions := getMeeplePositions(game.board)
+	structures := game.structureRegistry()
+	counted := map[int]bool{}
 
-	for len(meeplePositions) > 0 {
-		pos := getKey(meeplePositions)
-		tile := game.board[pos]
-		side := tile.meeple.sideIndex
-
-		delete(meeplePositions, pos)
+	for pos, tile := range game.board {
+		// Farmers only score at the end of the game. They are handled below
+		if tile.meeple.playerIndex == -1 || tile.meeple.isFarmer() {
+			continue
+		}
 
 		// Cloister tiles do not need to be calculated recursively. They can be short-cut
 		if tile.cloister && tile.meeple.sideIndex == SIDE_CENTER {
@@ -621,30 +801,176 @@ func (game GameState) updateImmediatePoints(playerScores *[]int) {
 			continue
 		}
 
-		searched := map[Pos]bool{}
-		meeples := make([]int, len(*playerScores), len(*playerScores))
-		score, positions, closed := calcRecursivePoints(game.board, pos, side, &searched, &meeples)
+		// Every structure is only counted once, no matter how many meeples are on it
+		root, ok := structures.structure(pos, tile.meeple.sideIndex)
+		if !ok || counted[root] {
+			continue
+		}
+		counted[root] = true
 
 		// Closed structures should be handled by the updateFinalPoints() function. Not here, as it must handle
 		// meeple removal as well!
-		if !closed {
-			bestPlayer := getBestPlayerIndex(meeples)
-			for playerIndex, count := range meeples {
-				if count == meeples[bestPlayer] {
-					(*playerScores)[playerIndex] += score
-				}
+		structure := structures.nodes[root]
+		if structure.openEdges == 0 {
+			continue
+		}
+
+		meeples := structure.meeples[:len(*playerScores)]
+		bestPlayer := getBestPlayerIndex(meeples)
+		for playerIndex, count := range meeples {
+			if count == meeples[bestPlayer] {
+				(*playerScores)[playerIndex] += structure.points()
 			}
 		}
+	}
+
+	// Fields are scored as if the game ended now
+	farmScores, _ := calcFarmPoints(game.board, len(*playerScores))
+	for playerIndex, score := range farmScores {
+		(*playerScores)[playerIndex] += score
+	}
+}
+
+// Scores all incomplete structures at the end of the game, as the official rules say:
+// Roads and cities get 1 point per tile (plus 1 per emblem), cloisters 1 point plus 1 per surrounding tile.
+// Fields are scored as well. All meeples go back to the players. This can be reversed with reverseLastMove() like any other move.
+func (game *GameState) finalizeGame() {
+	revMove := ReverseMove{}
+	revMove.boardToPlayerMeeple = ReverseMeeplePlacement{-1, Pos{10000, 10000}, -1}
+	revMove.finalization = true
+	revMove.deckIndex = -1
+	revMove.structures = game.structureRegistry().mark()
+	revMove.currentPlayer = game.currentPlayer
+	revMove.drawnTile = game.drawnTile
+
+	// Unfinished roads and cities are scored from the root of their structure. The meeples on them are
+	// collected first, so every structure is scored once and all of its meeples are removed together.
+	structures := game.structureRegistry()
+	var roots []int
+	meeplePositions := map[int][]Pos{}
+	for _, pos := range sortedPositions(getMeeplePositions(game.board)) {
+		tile := game.board[pos]
+		if tile.meeple.isFarmer() {
+			continue
+		}
 
-		// remove found meeple-positions from the initial set of meeplePositions! So we don't seach the same structure multiple times
-		for _, p := range positions {
-			delete(meeplePositions, p)
+		if tile.meeple.sideIndex == SIDE_CENTER {
+			score := 1 + countSurroundingTiles(game.board, pos)
+			game.players[tile.meeple.playerIndex].score += score
+			revMove.awardedPoints = append(revMove.awardedPoints, ReversePlayerPoints{tile.meeple.playerIndex, score})
+			game.cleanupUsedMeeplesFromBoard([]Pos{pos}, &revMove)
+			continue
 		}
+
+		root, _ := structures.structure(pos, tile.meeple.sideIndex)
+		if len(meeplePositions[root]) == 0 {
+			roots = append(roots, root)
+		}
+		meeplePositions[root] = append(meeplePositions[root], pos)
+	}
+
+	for _, root := range roots {
+		structure := structures.nodes[root]
+		score := structure.points()
+		meeples := structure.meeples[:len(game.players)]
+		bestPlayer := getBestPlayerIndex(meeples)
+		for playerIndex, count := range meeples {
+			if count == meeples[bestPlayer] {
+				game.players[playerIndex].score += score
+				revMove.awardedPoints = append(revMove.awardedPoints, ReversePlayerPoints{playerIndex, score})
+			}
+		}
+		game.cleanupUsedMeeplesFromBoard(meeplePositions[root], &revMove)
 	}
+
+	game.updateFarmPoints(&revMove)
+
+	game.lastMoves = append(game.lastMoves, revMove)
 }
 
-func generateInitialBoard(playerCount int) GameState {
+// Returns the indices of all players with the highest score. More than one player wins on a tie.
+func (game GameState) winners() (indices []int) {
+	best := 0
+	for i, p := range game.players {
+		switch {
+		case len(indices) == 0 || p.score > best:
+			best = p.score
+			indices = []int{i}
+		case p.score == best:
+			indices = append(indices, i)
+		}
+	}
+	return
+}
+
+// Returns a new game of the base game with a deck shuffled by the seed.
+func generateInitialBoard(playerCount int, seed int64) GameState {
 	startTile, tiles := getTiles()
+	return generateShuffledBoard(playerCount, startTile, tiles, seed)
+}
+
+// A random number source that counts the numbers it returned. A new source with the same seed gets to the
+// same position by skipping that many numbers, as the state of a rand.Source can't be saved itself.
+type countingSource struct {
+	source rand.Source64
+	count  uint64
+}
+
+func newCountingSource(seed int64) *countingSource {
+	return &countingSource{rand.NewSource(seed).(rand.Source64), 0}
+}
+
+func (s *countingSource) Int63() int64 {
+	s.count++
+	return s.source.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.count++
+	return s.source.Uint64()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.source.Seed(seed)
+	s.count = 0
+}
+
+// Skips random numbers until count numbers were returned since the seed.
+func (s *countingSource) skipTo(count uint64) {
+	for s.count < count {
+		s.Uint64()
+	}
+}
+
+// Sets the seed of the game and starts its random number generator over.
+func (game *GameState) setSeed(seed int64) {
+	game.seed = seed
+	game.rngSource = newCountingSource(seed)
+	game.rng = rand.New(game.rngSource)
+}
+
+// Returns a new game, which owns a random number generator with the given seed. The tiles are shuffled with it.
+func generateShuffledBoard(playerCount int, startTile Tile, tiles []Tile, seed int64) GameState {
+	game := generateInitialBoardFromTiles(playerCount, startTile, tiles)
+	game.setSeed(seed)
+	game.rng.Shuffle(len(game.tiles), func(i, j int) { game.tiles[i], game.tiles[j] = game.tiles[j], game.tiles[i] })
+	return game
+}
+
+// Returns a new game with the deck of the tile set and the enabled expansions, shuffled by the seed.
+func generateTileSetBoard(playerCount int, tileSet TileSet, expansions []string, seed int64) GameState {
+	startTile, tiles := tileSet.deck(expansions)
+	game := generateShuffledBoard(playerCount, startTile, tiles, seed)
+	game.tileSet = tileSet
+	return game
+}
+
+// Returns a new game with the tiles in the given order. The seed is 0 and the tiles are from the base tile set.
+// Panics for less than 1 or more than MAX_PLAYERS players, the callers check the count of user input.
+func generateInitialBoardFromTiles(playerCount int, startTile Tile, tiles []Tile) GameState {
+	if playerCount < 1 || playerCount > MAX_PLAYERS {
+		panic(fmt.Sprintf("generateInitialBoardFromTiles: %v players, but a game has between 1 and %v", playerCount, MAX_PLAYERS))
+	}
 	var players []Player
 	for i := 0; i < playerCount; i++ {
 		players = append(players, Player{i, 0, 6})
@@ -655,24 +981,81 @@ func generateInitialBoard(playerCount int) GameState {
 		players,
 		map[Pos]bool{Pos{-1, 0}: true, Pos{1, 0}: true, Pos{0, -1}: true, Pos{0, 1}: true},
 		[]ReverseMove{},
+		nil,
+		0,
+		-1,
+		nil,
+		0,
+		nil,
+		nil,
+		baseTileSet(),
 	}
+	game.setSeed(0)
 
 	game.board[Pos{0, 0}] = startTile
 
 	return game
 }
 
+// Draws the tile on top of the deck for the current player. As the official rules say, a tile that
+// can not be placed anywhere is discarded and the player draws again.
+// Returns false if the deck is empty, which ends the game.
+func (game *GameState) drawTile() (Tile, []Move, bool) {
+	for len(game.tiles) > 0 {
+		tile := game.tiles[0]
+		moves := generatePossibleMoves(game.board, []Tile{tile}, game.openPlacements, game.players[game.currentPlayer], len(game.players))
+		if len(moves) > 0 {
+			game.drawnTile = 0
+			return tile, moves, true
+		}
+		game.discardedTiles = append(game.discardedTiles, tile)
+		game.tiles = game.tiles[1:]
+	}
+	game.drawnTile = -1
+	return Tile{}, nil, false
+}
+
+// Puts the tiles that were discarded since there were the given number of discarded tiles back on top of
+// the deck and takes back the drawn tile. Together with reverseLastMove() this takes back a whole turn.
+func (game *GameState) restoreDiscardedTiles(discarded int) {
+	game.tiles = append(append([]Tile{}, game.discardedTiles[discarded:]...), game.tiles...)
+	game.discardedTiles = game.discardedTiles[:discarded]
+	game.drawnTile = -1
+}
+
+// Places the tile and scores all closed structures. A drawn tile is taken from the deck
+// and the turn goes to the next player.
 func (game *GameState) makeMove(move Move) {
 	revMove := ReverseMove{}
+	revMove.currentPlayer = game.currentPlayer
+	revMove.drawnTile = game.drawnTile
+	revMove.deckIndex = game.drawnTile
+	if game.drawnTile != -1 {
+		revMove.deckTile = game.tiles[game.drawnTile]
+		// Placing another tile would take the wrong tile from the deck without anybody noticing
+		if move.tile.id != revMove.deckTile.id {
+			panic(fmt.Sprintf("makeMove: the move places tile %v, but tile %v was drawn", move.tile.id, revMove.deckTile.id))
+		}
+		// Never shift the tiles in place. Others (like the remaining tiles of a GameView) might still look at them
+		game.tiles = append(game.tiles[:game.drawnTile:game.drawnTile], game.tiles[game.drawnTile+1:]...)
+	}
 	placeTile(game, move.tile, move.pos, &revMove)
 	game.updateFinalPoints(move.pos, &revMove)
 	game.lastMoves = append(game.lastMoves, revMove)
+
+	game.currentPlayer = (game.currentPlayer + 1) % len(game.players)
+	game.drawnTile = -1
 }
 
 func (game *GameState) reverseLastMove() {
 	lastMove := game.lastMoves[len(game.lastMoves)-1]
 	game.lastMoves = game.lastMoves[:len(game.lastMoves)-1]
 
+	// The registry keeps track of its own changes. If it was rebuilt in between, it is just rebuilt again
+	if game.structures == nil || !game.structures.rollback(lastMove.structures) {
+		game.invalidateStructures()
+	}
+
 	if lastMove.boardToPlayerMeeple.playerIndex != -1 {
 		tmp := game.board[lastMove.boardToPlayerMeeple.pos]
 		tmp.meeple = Meeple{-1, -1}
@@ -691,6 +1074,20 @@ func (game *GameState) reverseLastMove() {
 		game.players[p.playerIndex].score -= p.points
 	}
 
+	game.currentPlayer = lastMove.currentPlayer
+	game.drawnTile = lastMove.drawnTile
+
+	if lastMove.finalization {
+		return
+	}
+
+	if lastMove.deckIndex != -1 {
+		tiles := make([]Tile, 0, len(game.tiles)+1)
+		tiles = append(tiles, game.tiles[:lastMove.deckIndex]...)
+		tiles = append(tiles, lastMove.deckTile)
+		game.tiles = append(tiles, game.tiles[lastMove.deckIndex:]...)
+	}
+
 	delete(game.board, lastMove.removeTileFromBoard)
 	game.openPlacements[lastMove.removeTileFromBoard] = true
 
@@ -724,31 +1121,201 @@ func (game *GameState) selectBestMove(moves []Move, player Player) Move {
 	return bestMove
 }
 
+// The -seed flag. It is unset by default, so every seed including 0 can be given to replay a game.
+type seedFlag struct {
+	value int64
+	set   bool
+}
+
+func (f *seedFlag) String() string {
+	if !f.set {
+		return ""
+	}
+	return strconv.FormatInt(f.value, 10)
+}
+
+func (f *seedFlag) Set(s string) error {
+	value, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	f.value, f.set = value, true
+	return nil
+}
+
 func main() {
 
-	game := generateInitialBoard(3)
+	tileSetPath := flag.String("tiles", "", "Path to a tile set file. Uses the base game if empty")
+	expansions := flag.String("expansions", "", "Comma separated list of expansions of the tile set to play with")
+	agentNames := flag.String("agents", "greedy,greedy,greedy", "Comma separated list of agents, one per player: random, first, greedy, mcts, expectimax, human or bot:<command> for an external bot. mcts and expectimax take options like mcts:iterations=5000:rollout=greedy. A label like greedy@v2 names the agent in the ratings")
+	var seed seedFlag
+	flag.Var(&seed, "seed", "Seed of the game. The same seed and agents replay the same game. Picks a random seed if not set")
+	loadPath := flag.String("load", "", "Continues the saved game from this file")
+	savePath := flag.String("save", "", "Saves the finished game (including all moves) to this file")
+	recordPath := flag.String("record", "", "Writes the record of the finished game to this file")
+	replayPath := flag.String("replay", "", "Steps through the game record in this file instead of playing")
+	svgPath := flag.String("svg", "", "Draws the finished board as SVG into this file")
+	pngPath := flag.String("png", "", "Draws the finished board as PNG into this file")
+	gifPath := flag.String("gif", "", "Writes an animation of the whole game as GIF into this file")
+	tileSize := flag.Int("tilesize", 60, "Size of a tile in pixels for -svg, -png and -gif")
+	serveAddr := flag.String("serve", "", "Hosts games over HTTP on this address (like :8080) instead of playing")
+	tournament := flag.String("tournament", "", "Comma separated list of agents (like -agents) to play a tournament between instead of a single game")
+	tournamentGames := flag.Int("games", 10, "Games per table in a tournament. Should be a multiple of -tablesize, so every seat plays every deck")
+	tableSize := flag.Int("tablesize", 2, "Players per game in a tournament. Every combination of that many agents plays")
+	parallel := flag.Int("parallel", runtime.NumCPU(), "Games played at the same time in a tournament")
+	csvPath := flag.String("csv", "", "Writes the statistics of the tournament as CSV into this file")
+	jsonPath := flag.String("json", "", "Writes the statistics and all results of the tournament as JSON into this file")
+	ratingsPath := flag.String("ratings", "", "Adds the results of the played games to the rating history in this file and shows the leaderboard")
+	showRatings := flag.Bool("leaderboard", false, "Shows the leaderboard of the -ratings file instead of playing")
+	flag.Parse()
+
+	if *tileSize <= 0 {
+		fmt.Fprintln(os.Stderr, "The tile size must be positive")
+		os.Exit(1)
+	}
 
-	for rounds := 0; rounds < 10; rounds++ {
-		i := 0
-		for i < len(game.tiles) {
-			for _, player := range game.players {
-				if i >= len(game.tiles) {
-					break
-				}
-				tile := game.tiles[i]
-				i += 1
+	if *serveAddr != "" {
+		var tileSets []TileSet
+		if *tileSetPath != "" {
+			tileSet, err := loadTileSet(*tileSetPath)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			tileSets = append(tileSets, tileSet)
+		}
+		fmt.Println("Serving games on", *serveAddr)
+		if err := http.ListenAndServe(*serveAddr, newGameServer(tileSets...)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *replayPath != "" {
+		tileSets := func(name string) (TileSet, error) {
+			if *tileSetPath == "" {
+				return TileSet{}, fmt.Errorf("unknown tile set %q, use -tiles to load it", name)
+			}
+			return loadTileSet(*tileSetPath)
+		}
+		if err := replayFile(*replayPath, tileSets, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-				moves := generatePossibleMoves(game.board, []Tile{tile}, game.openPlacements, player)
-				if len(moves) > 0 {
-					//move := moves[rand.Intn(len(moves))]
-					//move := moves[0]
+	if *showRatings {
+		if *ratingsPath == "" {
+			fmt.Fprintln(os.Stderr, "The leaderboard needs the rating history, use -ratings")
+			os.Exit(1)
+		}
+		if err := showLeaderboard(*ratingsPath, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-					move := game.selectBestMove(moves, player)
-					game.makeMove(move)
+	if !seed.set {
+		seed.value = time.Now().UnixNano()
+	}
+	fmt.Println("Seed:", seed.value)
 
-				}
+	var enabled []string
+	if *expansions != "" {
+		enabled = strings.Split(*expansions, ",")
+	}
+
+	if *tournament != "" {
+		config := defaultTournamentConfig(strings.Split(*tournament, ","))
+		for i := range config.agents {
+			config.agents[i] = strings.TrimSpace(config.agents[i])
+		}
+		config.tableSize = *tableSize
+		config.games = *tournamentGames
+		config.seed = seed.value
+		config.parallel = *parallel
+		config.expansions = enabled
+		if *tileSetPath != "" {
+			tileSet, err := loadTileSet(*tileSetPath)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			config.tileSet = tileSet
+		}
+		results, err := runTournament(config)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		report := config.report(results)
+		report.writeText(os.Stdout)
+		if *csvPath != "" {
+			if err := writeFile(*csvPath, report.writeCSV); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		if *jsonPath != "" {
+			if err := writeFile(*jsonPath, report.writeJSON); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
 			}
 		}
+		if *ratingsPath != "" {
+			fmt.Println()
+			if err := recordRatings(*ratingsPath, config.ratedGames(results), os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		return
+	}
+
+	playerNames := strings.Split(*agentNames, ",")
+	for i := range playerNames {
+		playerNames[i] = strings.TrimSpace(playerNames[i])
+	}
+	agents, err := newAgents(playerNames)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	game := generateInitialBoard(len(agents), seed.value)
+	if *tileSetPath != "" {
+		tileSet, err := loadTileSet(*tileSetPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		game = generateTileSetBoard(len(agents), tileSet, enabled, seed.value)
+	}
+	if *loadPath != "" {
+		game, err = loadGameFile(*loadPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if len(game.players) != len(agents) {
+			fmt.Fprintf(os.Stderr, "The saved game has %v players, but %v agents are given\n", len(game.players), len(agents))
+			os.Exit(1)
+		}
+	}
+
+	humans := false
+	for _, name := range playerNames {
+		humans = humans || unlabeledAgentName(name) == "human"
+	}
+	if humans {
+		if !newInteractiveGame(&game, agents, playerNames, os.Stdin, os.Stdout).run() {
+			return
+		}
+	} else if !runGame(&game, agents) {
+		return
 	}
 
 	drawField(game.board)
@@ -756,5 +1323,48 @@ func main() {
 	for _, p := range game.players {
 		fmt.Println(p)
 	}
+	fmt.Println("Winner:", game.winners())
+
+	if *ratingsPath != "" {
+		if err := recordRatings(*ratingsPath, []ratedGame{newRatedGame(game, playerNames)}, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
 
+	if *savePath != "" {
+		if err := saveGameFile(game, *savePath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	renderOptions := defaultRenderOptions()
+	renderOptions.tileSize = float64(*tileSize)
+	if *svgPath != "" {
+		if err := writeGameSVG(game, renderOptions, *svgPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *pngPath != "" {
+		if err := writeGamePNG(game, renderOptions, *pngPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *gifPath != "" {
+		err := writeFile(*gifPath, func(w io.Writer) error {
+			return writeGameGIF(w, game, renderOptions, 50)
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *recordPath != "" {
+		if err := writeRecordFile(game, playerNames, enabled, *recordPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
 }
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Events of a game, pushed to the clients of /games/<id>/events as JSON text messages. Every event has its
+// kind in "type". After connecting, a client gets the state of the game and from then on every change in order:
+// A move is followed by its scores and returned meeples and then by the next turn. The last move is followed
+// by the final scoring and the finished event instead. An undo sends the whole state again.
+const (
+	EVENT_STATE           = "state"
+	EVENT_MOVE            = "move"
+	EVENT_SCORE           = "score"
+	EVENT_MEEPLE_RETURNED = "meepleReturned"
+	EVENT_TURN            = "turn"
+	EVENT_FINISHED        = "finished"
+)
+
+// Events for a client, that doesn't read them fast enough, are dropped together with the client.
+const EVENT_BUFFER = 256
+
+// The whole game. Clients replace everything they know about it.
+type stateEvent struct {
+	Type  string       `json:"type"`
+	State gameResponse `json:"state"`
+}
+
+// Player placed the tile (with its meeple). Move is the notation of the move.
+type moveEvent struct {
+	Type   string    `json:"type"`
+	Player int       `json:"player"`
+	Move   string    `json:"move"`
+	Tile   savedTile `json:"tile"`
+}
+
+// Player got points for a closed structure or in the final scoring. Score is the new total.
+type scoreEvent struct {
+	Type   string `json:"type"`
+	Player int    `json:"player"`
+	Points int    `json:"points"`
+	Score  int    `json:"score"`
+}
+
+// The meeple on the side of the tile at x,y went back to its player.
+type meepleReturnedEvent struct {
+	Type string `json:"type"`
+	savedMeeplePlacement
+}
+
+// Player has to place the tile next.
+type turnEvent struct {
+	Type   string `json:"type"`
+	Player int    `json:"player"`
+	Tile   string `json:"tile"`
+}
+
+type finishedEvent struct {
+	Type    string `json:"type"`
+	Scores  []int  `json:"scores"`
+	Winners []int  `json:"winners"`
+}
+
+// Returns the score and meeple events of the move, which is on top of lastMoves.
+func (g *serverGame) scoreEvents(m ReverseMove) (events []interface{}) {
+	// The scores before the move, so each event has the total up to then
+	scores := map[int]int{}
+	for _, p := range g.game.players {
+		scores[p.index] = p.score
+	}
+	for _, p := range m.awardedPoints {
+		scores[p.playerIndex] -= p.points
+	}
+
+	for _, p := range m.awardedPoints {
+		scores[p.playerIndex] += p.points
+		events = append(events, scoreEvent{EVENT_SCORE, p.playerIndex, p.points, scores[p.playerIndex]})
+	}
+	for _, r := range m.playerToBoardMeeple {
+		events = append(events, meepleReturnedEvent{EVENT_MEEPLE_RETURNED, toSavedPlacement(r)})
+	}
+	return
+}
+
+// Returns the events of the move, right after it was made.
+func (g *serverGame) moveEvents(move Move) []interface{} {
+	last := g.game.lastMoves[len(g.game.lastMoves)-1]
+	notation, _ := g.game.moveNotation(move)
+	rotation, _ := g.game.tileSet.rotation(move.tile)
+	tile := savedTile{toSavedPos(move.pos), g.game.tileSet.tileName(move.tile.id), rotation, nil}
+	if move.tile.meeple.playerIndex != -1 {
+		tile.Meeple = &savedMeeple{move.tile.meeple.playerIndex, move.tile.meeple.sideIndex}
+	}
+
+	events := []interface{}{moveEvent{EVENT_MOVE, last.currentPlayer, notation, tile}}
+	return append(events, g.scoreEvents(last)...)
+}
+
+// Returns the events after drawing: the next turn or the end of the game.
+func (g *serverGame) drawEvents() []interface{} {
+	if !g.finished() {
+		return []interface{}{turnEvent{EVENT_TURN, g.game.currentPlayer, g.game.tileSet.tileName(g.tile.id)}}
+	}
+
+	events := g.scoreEvents(g.game.lastMoves[len(g.game.lastMoves)-1])
+	var scores []int
+	for _, p := range g.game.players {
+		scores = append(scores, p.score)
+	}
+	return append(events, finishedEvent{EVENT_FINISHED, scores, g.game.winners()})
+}
+
+// Sends the events to all listeners. Needs the lock of the game, so the events keep their order.
+func (g *serverGame) publish(events ...interface{}) {
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		for listener := range g.listeners {
+			select {
+			case listener <- data:
+			default:
+				delete(g.listeners, listener)
+				close(listener)
+			}
+		}
+	}
+}
+
+func (g *serverGame) publishState() {
+	if state, err := g.state(); err == nil {
+		g.publish(stateEvent{EVENT_STATE, state})
+	}
+}
+
+func (g *serverGame) closeListeners() {
+	for listener := range g.listeners {
+		delete(g.listeners, listener)
+		close(listener)
+	}
+}
+
+// Upgrades the request to a WebSocket and sends the events of the game until the client leaves.
+func (g *serverGame) streamEvents(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+
+	events := make(chan []byte, EVENT_BUFFER)
+	g.mutex.Lock()
+	state, err := g.state()
+	if err == nil {
+		data, _ := json.Marshal(stateEvent{EVENT_STATE, state})
+		events <- data
+		g.listeners[events] = true
+	}
+	g.mutex.Unlock()
+	if err != nil {
+		conn.close(WS_CLOSE_INTERNAL_ERROR, err.Error())
+		return
+	}
+
+	// Clients only send control frames. Reading answers them and notices when the client leaves
+	done := make(chan bool)
+	go func() {
+		for {
+			if _, _, err := conn.readMessage(); err != nil {
+				close(done)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case data, ok := <-events:
+			// The game was deleted or the client was too slow
+			if !ok {
+				conn.close(WS_CLOSE_GOING_AWAY, "")
+				return
+			}
+			if err := conn.writeText(data); err == nil {
+				continue
+			}
+		case <-done:
+		}
+
+		g.mutex.Lock()
+		delete(g.listeners, events)
+		g.mutex.Unlock()
+		conn.close(WS_CLOSE_NORMAL, "")
+		return
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// The opponent plays the move that is worst for us (paranoid search).
+	OPPONENT_MIN = iota
+	// The opponent plays the move selectBestMove() would play.
+	OPPONENT_GREEDY
+	// The opponent plays a random move, so all moves are equally likely.
+	OPPONENT_RANDOM
+)
+
+// Evaluates the game from the point of view of the given player. Bigger is better.
+type Evaluation func(game *GameState, player int) float64
+
+type ExpectimaxConfig struct {
+	// Number of moves (plies) to search, including our own move. Draws don't count.
+	depth    int
+	opponent int
+	evaluate Evaluation
+	// Bounds of the evaluation function. Star1/Star2 pruning only works with finite bounds.
+	evalMin float64
+	evalMax float64
+	// Only the best moves (by a static evaluation) are searched below the root. 0 searches all moves.
+	beamWidth int
+	// Enables alpha-beta pruning for max/min nodes and Star1/Star2 pruning for chance nodes.
+	pruning bool
+}
+
+func defaultExpectimaxConfig() ExpectimaxConfig {
+	return ExpectimaxConfig{2, OPPONENT_GREEDY, evaluateScoreDifference, -1, 1, 8, true}
+}
+
+// The options of "expectimax:key=value" agents, see newAgent().
+func (config *ExpectimaxConfig) options() map[string]func(string) error {
+	return map[string]func(string) error{
+		"depth":    intOption(&config.depth),
+		"opponent": enumOption(&config.opponent, "min", "greedy", "random"),
+		"beam":     intOption(&config.beamWidth),
+		"pruning":  boolOption(&config.pruning),
+	}
+}
+
+// The score (including the points of unfinished structures) compared to the best other player.
+// The result is in [-1, 1].
+func evaluateScoreDifference(game *GameState, player int) float64 {
+	scores := make([]int, len(game.players), len(game.players))
+	game.updateImmediatePoints(&scores)
+	for p := range scores {
+		scores[p] += game.players[p].score
+	}
+
+	bestOther := 0
+	for p, score := range scores {
+		if p != player && (bestOther == 0 || score > bestOther) {
+			bestOther = score
+		}
+	}
+	return math.Tanh(float64(scores[player]-bestOther) / 20.0)
+}
+
+type expectimaxSearch struct {
+	config ExpectimaxConfig
+	root   int
+	// Number of searched nodes. Just for statistics.
+	nodes int
+}
+
+// One possible outcome of a draw.
+type expectimaxOutcome struct {
+	probability float64
+	moves       []Move
+	deck        []Tile
+}
+
+// Returns a copy of the deck without the first tile of the given type.
+func withoutTile(deck []Tile, id int) []Tile {
+	out := make([]Tile, 0, len(deck))
+	removed := false
+	for _, t := range deck {
+		if !removed && t.id == id {
+			removed = true
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// The part of the probability that is not searched yet, multiplied with a bound of the evaluation.
+func remainingBound(probability, bound float64) float64 {
+	if probability >= 1 {
+		return 0
+	}
+	return (1 - probability) * bound
+}
+
+func (s *expectimaxSearch) evaluate(game *GameState) float64 {
+	s.nodes++
+	return s.config.evaluate(game, s.root)
+}
+
+// Returns the value after a move was made. The next player draws the next tile.
+func (s *expectimaxSearch) afterMove(game *GameState, player int, deck []Tile, depth int, alpha, beta float64) float64 {
+	if len(deck) == 0 {
+		game.finalizeGame()
+		value := s.evaluate(game)
+		game.reverseLastMove()
+		return value
+	}
+	if depth <= 0 {
+		return s.evaluate(game)
+	}
+	return s.chance(game, player, deck, depth, alpha, beta)
+}
+
+// All possible draws, weighted by the number of remaining tiles of each type:
+// remaining tile_type * tile_count / all_tile_count.
+// Tiles that can't be placed are discarded and drawn again, so they are left out.
+func (s *expectimaxSearch) outcomes(game *GameState, player int, deck []Tile) (outcomes []expectimaxOutcome) {
+	counts := map[int]int{}
+	var order []Tile
+	for _, t := range deck {
+		if counts[t.id] == 0 {
+			order = append(order, t)
+		}
+		counts[t.id] += 1
+	}
+
+	total := 0
+	for _, t := range order {
+		moves := generatePossibleMoves(game.board, []Tile{t}, game.openPlacements, game.players[player], len(game.players))
+		if len(moves) == 0 {
+			continue
+		}
+		total += counts[t.id]
+		outcomes = append(outcomes, expectimaxOutcome{float64(counts[t.id]), moves, withoutTile(deck, t.id)})
+	}
+	for i := range outcomes {
+		outcomes[i].probability /= float64(total)
+	}
+	return
+}
+
+// Star1 over a list of children with their probabilities. Star2 probing is done before, in chance().
+func (s *expectimaxSearch) star1(probabilities []float64, child func(i int, alpha, beta float64) float64, alpha, beta float64) float64 {
+	L, U := s.config.evalMin, s.config.evalMax
+	sum, done := 0.0, 0.0
+
+	for i, p := range probabilities {
+		childAlpha, childBeta := L, U
+		if s.config.pruning {
+			childAlpha = math.Max(L, (alpha-sum-remainingBound(done+p, U))/p)
+			childBeta = math.Min(U, (beta-sum-remainingBound(done+p, L))/p)
+		}
+
+		sum += p * child(i, childAlpha, childBeta)
+		done += p
+
+		if s.config.pruning {
+			if upper := sum + remainingBound(done, U); upper <= alpha {
+				return upper
+			}
+			if lower := sum + remainingBound(done, L); lower >= beta {
+				return lower
+			}
+		}
+	}
+	return sum
+}
+
+func (s *expectimaxSearch) chance(game *GameState, player int, deck []Tile, depth int, alpha, beta float64) float64 {
+	outcomes := s.outcomes(game, player, deck)
+	if len(outcomes) == 0 {
+		// Nothing can be placed anymore. The game is over.
+		return s.afterMove(game, player, nil, depth, alpha, beta)
+	}
+
+	probabilities := make([]float64, len(outcomes), len(outcomes))
+	for i, o := range outcomes {
+		probabilities[i] = o.probability
+	}
+
+	// Star2: Probing just the first move of every outcome gives a lower bound for max nodes
+	// (or an upper bound for min nodes). That might be enough for a cutoff without a full search.
+	maxNode := player == s.root
+	if s.config.pruning && (maxNode || s.config.opponent == OPPONENT_MIN) {
+		bound := 0.0
+		for i, o := range outcomes {
+			// The probed move must be one of the moves that are searched later on
+			o.moves = s.beam(game, o.moves, maxNode)
+			outcomes[i].moves = o.moves
+
+			game.makeMove(o.moves[0])
+			bound += o.probability * s.afterMove(game, (player+1)%len(game.players), o.deck, depth-1, s.config.evalMin, s.config.evalMax)
+			game.reverseLastMove()
+		}
+		if maxNode && bound >= beta {
+			return bound
+		}
+		if !maxNode && bound <= alpha {
+			return bound
+		}
+	}
+
+	return s.star1(probabilities, func(i int, alpha, beta float64) float64 {
+		return s.decision(game, player, outcomes[i].moves, outcomes[i].deck, depth, alpha, beta)
+	}, alpha, beta)
+}
+
+// Only keeps the best moves by a static evaluation (after the move).
+func (s *expectimaxSearch) beam(game *GameState, moves []Move, maximize bool) []Move {
+	if s.config.beamWidth <= 0 || len(moves) <= s.config.beamWidth {
+		return moves
+	}
+	values := make([]float64, len(moves), len(moves))
+	indices := make([]int, len(moves), len(moves))
+	for i, m := range moves {
+		game.makeMove(m)
+		values[i] = s.evaluate(game)
+		game.reverseLastMove()
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(a, b int) bool {
+		if maximize {
+			return values[indices[a]] > values[indices[b]]
+		}
+		return values[indices[a]] < values[indices[b]]
+	})
+
+	best := make([]Move, s.config.beamWidth, s.config.beamWidth)
+	for i := range best {
+		best[i] = moves[indices[i]]
+	}
+	return best
+}
+
+// A node where the player has to choose one of the moves for the drawn tile.
+func (s *expectimaxSearch) decision(game *GameState, player int, moves []Move, deck []Tile, depth int, alpha, beta float64) float64 {
+	next := (player + 1) % len(game.players)
+	value := func(move Move, alpha, beta float64) float64 {
+		game.makeMove(move)
+		v := s.afterMove(game, next, deck, depth-1, alpha, beta)
+		game.reverseLastMove()
+		return v
+	}
+
+	switch {
+	case player == s.root:
+		best := math.Inf(-1)
+		for _, m := range s.beam(game, moves, true) {
+			best = math.Max(best, value(m, math.Max(alpha, best), beta))
+			if s.config.pruning && best >= beta {
+				break
+			}
+		}
+		return best
+
+	case s.config.opponent == OPPONENT_GREEDY:
+		return value(game.selectBestMove(moves, game.players[player]), alpha, beta)
+
+	case s.config.opponent == OPPONENT_RANDOM:
+		probabilities := make([]float64, len(moves), len(moves))
+		for i := range probabilities {
+			probabilities[i] = 1.0 / float64(len(moves))
+		}
+		return s.star1(probabilities, func(i int, alpha, beta float64) float64 {
+			return value(moves[i], alpha, beta)
+		}, alpha, beta)
+
+	default:
+		best := math.Inf(1)
+		for _, m := range s.beam(game, moves, false) {
+			best = math.Min(best, value(m, alpha, math.Min(beta, best)))
+			if s.config.pruning && best <= alpha {
+				break
+			}
+		}
+		return best
+	}
+}
+
+// Returns the best move, its value and the number of searched nodes.
+func (game *GameState) expectimax(moves []Move, player Player, remaining []Tile, config ExpectimaxConfig) (Move, float64, int) {
+	s := expectimaxSearch{config: config, root: player.index}
+	next := (player.index + 1) % len(game.players)
+
+	best, bestValue := 0, math.Inf(-1)
+	for i, m := range moves {
+		game.makeMove(m)
+		v := s.afterMove(game, next, remaining, config.depth-1, math.Max(bestValue, config.evalMin), config.evalMax)
+		game.reverseLastMove()
+		if v > bestValue {
+			best, bestValue = i, v
+		}
+	}
+	return moves[best], bestValue, s.nodes
+}
+
+// Selects a move with a depth-limited expectimax search. The next draws are chance nodes, weighted
+// by the remaining tiles. remaining are the tiles that will be drawn after this move.
+func (game *GameState) selectExpectimaxMove(moves []Move, player Player, remaining []Tile, config ExpectimaxConfig) Move {
+	move, _, _ := game.expectimax(moves, player, remaining, config)
+	return move
+}
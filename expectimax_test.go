@@ -0,0 +1,67 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExpectimaxOutcomes(t *testing.T) {
+	game := generateInitialBoard(2, 0)
+	_, tiles := getTiles()
+
+	s := expectimaxSearch{config: defaultExpectimaxConfig(), root: 0}
+	outcomes := s.outcomes(&game, 1, tiles)
+
+	counts := map[int]int{}
+	for _, tile := range tiles {
+		counts[tile.id] += 1
+	}
+
+	sum := 0.0
+	for _, o := range outcomes {
+		sum += o.probability
+		id := o.moves[0].tile.id
+		if expected := float64(counts[id]) / float64(len(tiles)); math.Abs(o.probability-expected) > 1e-9 {
+			t.Errorf("Tile %v has wrong probability. %v != %v (expected)", id, o.probability, expected)
+		}
+		if len(o.deck) != len(tiles)-1 {
+			t.Errorf("Exactly one tile should be removed from the deck. %v tiles left", len(o.deck))
+		}
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("Probabilities should add up to 1 but are %v", sum)
+	}
+}
+
+func TestExpectimaxPruning(t *testing.T) {
+	for _, opponent := range []int{OPPONENT_MIN, OPPONENT_RANDOM, OPPONENT_GREEDY} {
+		game := generateInitialBoard(2, 0)
+		i := playRandomMoves(&game, 6)
+
+		player := game.players[0]
+		moves := generatePossibleMoves(game.board, []Tile{game.tiles[i]}, game.openPlacements, player, len(game.players))
+		remaining := game.tiles[i+1 : i+4]
+		boardSize, moveCount, openPlacements := len(game.board), len(game.lastMoves), len(game.openPlacements)
+
+		config := defaultExpectimaxConfig()
+		config.depth = 2
+		config.beamWidth = 2
+		config.opponent = opponent
+
+		config.pruning = false
+		_, value, nodes := game.expectimax(moves, player, remaining, config)
+		config.pruning = true
+		_, prunedValue, prunedNodes := game.expectimax(moves, player, remaining, config)
+
+		if math.Abs(value-prunedValue) > 1e-9 {
+			t.Errorf("Opponent %v: pruning should not change the value. %v != %v (expected)", opponent, prunedValue, value)
+		}
+		// Star2 probing costs extra nodes, so this only holds for the paranoid search
+		if opponent == OPPONENT_MIN && prunedNodes > nodes {
+			t.Errorf("Opponent %v: pruning should not search more nodes. %v > %v", opponent, prunedNodes, nodes)
+		}
+		if len(game.board) != boardSize || len(game.lastMoves) != moveCount || len(game.openPlacements) != openPlacements {
+			t.Errorf("Expectimax changed the game")
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+// Fields (grass areas) can not be described by the 4 sides of a tile alone, because a road
+// splits the grass of its side into two halves. So every side is split into two field
+// segments, which gives 8 segments, going around the tile in side order:
+//
+//	side 0 (left):  0 == upper half, 1 == lower half
+//	side 1 (down):  2 == left half,  3 == right half
+//	side 2 (right): 4 == lower half, 5 == upper half
+//	side 3 (up):    6 == right half, 7 == left half
+//
+// The segment i of a tile touches the segment fieldNeighbourSegment(i) of the neighbouring tile.
+// A farmer on a field is a Meeple with sideIndex == SIDE_FIELD + segment.
+//
+// Which segments of a tile belong to the same field is derived from the sides and connections:
+// Roads running through the tile, cities connecting multiple sides and road junctions
+// separate the segments into multiple fields.
+
+const FIELD_SEGMENTS = 8
+
+// Returns the side a field segment belongs to.
+func fieldSegmentSide(segment int) int {
+	return segment / 2
+}
+
+// Returns the segment of the neighbouring tile (in direction of the segments side), that touches the given segment.
+func fieldNeighbourSegment(segment int) int {
+	side := fieldSegmentSide(segment)
+	return 2*((side+2)%4) + 1 - segment%2
+}
+
+func (t Tile) isConnected(a, b int) bool {
+	return a == b || (t.connections>>(a*4))&(1<<b) != 0
+}
+
+func (m Meeple) isFarmer() bool {
+	return m.playerIndex != -1 && m.sideIndex >= SIDE_FIELD
+}
+
+// Returns true, if the meeple is a farmer on one of the given segments.
+func (m Meeple) onFieldSegment(segments ...int) bool {
+	if !m.isFarmer() {
+		return false
+	}
+	for _, s := range segments {
+		if m.sideIndex == SIDE_FIELD+s {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns all groups of sides that separate fields from each other, as bitmasks of sides. Each of them
+// has at least two sides, as a structure touching just one side can't split a field.
+// This is called a lot during the search, so it must not allocate.
+func (t Tile) fieldBarriers() (barriers [4]uint8, count int) {
+	var done, roadEnds uint8
+
+	for side := 0; side < 4; side++ {
+		if done&(1<<side) != 0 || t.sides[side] == AREA_GRASS {
+			continue
+		}
+		group, size := uint8(1<<side), 1
+		for other := side + 1; other < 4; other++ {
+			if done&(1<<other) == 0 && t.sides[other] == t.sides[side] && t.isConnected(side, other) {
+				group |= 1 << other
+				size++
+			}
+		}
+		done |= group
+
+		switch {
+		case size > 1:
+			barriers[count] = group
+			count++
+		case t.sides[side] == AREA_ROAD:
+			roadEnds |= group
+		}
+	}
+
+	// Multiple roads ending on the same tile meet in a junction (or the cloister) and split the fields between them.
+	if roadEnds&(roadEnds-1) != 0 {
+		barriers[count] = roadEnds
+		count++
+	}
+	return
+}
+
+// Returns true, if the barrier lies between the segments a and b (a < b). That is the case, if it
+// touches the border of the tile on both ways around the tile from a to b.
+func separates(barrier uint8, a, b int) bool {
+	inside, outside := false, false
+	for side := 0; side < 4; side++ {
+		if barrier&(1<<side) == 0 {
+			continue
+		}
+		// The middle of a side lies right between its two segments
+		middle := 2*side + 1
+		if middle > a && middle <= b {
+			inside = true
+		} else {
+			outside = true
+		}
+	}
+	return inside && outside
+}
+
+// Returns for every segment the lowest segment of the same field on this tile. So every
+// field is represented by its lowest segment. Segments of city sides are -1.
+func (t Tile) fieldSegments() (fields [FIELD_SEGMENTS]int) {
+	barriers, count := t.fieldBarriers()
+
+	for a := 0; a < FIELD_SEGMENTS; a++ {
+		fields[a] = -1
+		if t.sides[fieldSegmentSide(a)] == AREA_CITY {
+			continue
+		}
+		fields[a] = a
+		for b := 0; b < a; b++ {
+			if fields[b] != b {
+				continue
+			}
+			separated := false
+			for _, barrier := range barriers[:count] {
+				if separates(barrier, b, a) {
+					separated = true
+					break
+				}
+			}
+			if !separated {
+				fields[a] = b
+				break
+			}
+		}
+	}
+	return
+}
+
+// Returns all city sides that touch the field with the given segment on this tile.
+// fields must be the result of t.fieldSegments().
+func (t Tile) fieldCitySides(fields [FIELD_SEGMENTS]int, segment int) (sides []int) {
+	field := fields[segment]
+	for side := 0; side < 4; side++ {
+		if t.sides[side] != AREA_CITY {
+			continue
+		}
+		// The segments right before and right after the side
+		before, after := (2*side+FIELD_SEGMENTS-1)%FIELD_SEGMENTS, (2*side+2)%FIELD_SEGMENTS
+		if (fields[before] == field && field != -1) || (fields[after] == field && field != -1) {
+			sides = append(sides, side)
+		}
+	}
+	return
+}
+
+// Walks the road or city that contains the given side and returns all visited sides
+// and if the structure is closed.
+func structureSegments(board map[Pos]Tile, pos Pos, side int, visited map[Visited]bool) bool {
+	closed := true
+	stack := []Visited{Visited{pos, side}}
+
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[v] {
+			continue
+		}
+
+		tile := board[v.pos]
+		for s := 0; s < 4; s++ {
+			if tile.sides[s] != tile.sides[v.side] || !tile.isConnected(v.side, s) {
+				continue
+			}
+			visited[Visited{v.pos, s}] = true
+
+			next := add(v.pos, g_sides[s])
+			if _, ok := board[next]; !ok {
+				closed = false
+				continue
+			}
+			stack = append(stack, Visited{next, (s + 2) % 4})
+		}
+	}
+	return closed
+}
+
+// Walks the whole field that contains the given segment. It counts the farmers per player,
+// collects the tiles with farmers and the city sides bordering the field.
+func fieldSegmentsOnBoard(board boardView, pos Pos, segment int, visited map[Visited]bool, farmers []int) (positions []Pos, cities []Visited) {
+	start, _ := board.get(pos)
+	stack := []Visited{Visited{pos, start.fieldSegments()[segment]}}
+
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[v] {
+			continue
+		}
+		visited[v] = true
+
+		tile, _ := board.get(v.pos)
+		fields := tile.fieldSegments()
+
+		if tile.meeple.isFarmer() && fields[tile.meeple.sideIndex-SIDE_FIELD] == v.side {
+			farmers[tile.meeple.playerIndex] += 1
+			positions = append(positions, v.pos)
+		}
+
+		for _, side := range tile.fieldCitySides(fields, v.side) {
+			cities = append(cities, Visited{v.pos, side})
+		}
+
+		for s := 0; s < FIELD_SEGMENTS; s++ {
+			if fields[s] != v.side {
+				continue
+			}
+			next := add(v.pos, g_sides[fieldSegmentSide(s)])
+			if t, ok := board.get(next); ok {
+				stack = append(stack, Visited{next, t.fieldSegments()[fieldNeighbourSegment(s)]})
+			}
+		}
+	}
+	return
+}
+
+// Calculates the points of all fields with farmers on them. Every completed city bordering a field
+// gives 3 points to the players with the most farmers on that field.
+// Returns the points per player and all positions of farmers that were counted.
+func calcFarmPoints(board map[Pos]Tile, playerCount int) (scores []int, positions []Pos) {
+	scores = make([]int, playerCount, playerCount)
+
+	visitedFields := map[Visited]bool{}
+	// Every city side is mapped to the first side of its city that was found, so each city is only counted once.
+	cityIds := map[Visited]Visited{}
+	closedCities := map[Visited]bool{}
+
+	for _, pos := range sortedPositions(getMeeplePositions(board)) {
+		tile := board[pos]
+		if !tile.meeple.isFarmer() {
+			continue
+		}
+		segment := tile.meeple.sideIndex - SIDE_FIELD
+		if visitedFields[Visited{pos, tile.fieldSegments()[segment]}] {
+			continue
+		}
+
+		farmers := make([]int, playerCount, playerCount)
+		farmerPositions, citySides := fieldSegmentsOnBoard(viewBoard(board), pos, segment, visitedFields, farmers)
+		positions = append(positions, farmerPositions...)
+
+		cities := map[Visited]bool{}
+		for _, c := range citySides {
+			id, ok := cityIds[c]
+			if !ok {
+				visited := map[Visited]bool{}
+				closed := structureSegments(board, c.pos, c.side, visited)
+				for v := range visited {
+					cityIds[v] = c
+				}
+				closedCities[c] = closed
+				id = c
+			}
+			if closedCities[id] {
+				cities[id] = true
+			}
+		}
+
+		if bestPlayer := getBestPlayerIndex(farmers); bestPlayer != -1 {
+			for playerIndex, count := range farmers {
+				if count == farmers[bestPlayer] {
+					scores[playerIndex] += 3 * len(cities)
+				}
+			}
+		}
+	}
+	return
+}
+
+// Scores all fields at the end of the game and returns the farmers to the players.
+func (game *GameState) updateFarmPoints(revMove *ReverseMove) {
+	scores, positions := calcFarmPoints(game.board, len(game.players))
+
+	for playerIndex, score := range scores {
+		if score > 0 {
+			game.players[playerIndex].score += score
+			revMove.awardedPoints = append(revMove.awardedPoints, ReversePlayerPoints{playerIndex, score})
+		}
+	}
+	game.cleanupUsedMeeplesFromBoard(positions, revMove)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFieldSegments(t *testing.T) {
+	cases := []struct {
+		name     string
+		tile     Tile
+		expected [FIELD_SEGMENTS]int
+	}{
+		// Road through the tile and a city on top
+		{"D", Tile{3, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_CITY}, false, false, 0x0104, Meeple{-1, -1}}, [FIELD_SEGMENTS]int{0, 1, 1, 1, 1, 0, -1, -1}},
+		{"U", Tile{20, [4]Area{AREA_GRASS, AREA_ROAD, AREA_GRASS, AREA_ROAD}, false, false, 0x2080, Meeple{-1, -1}}, [FIELD_SEGMENTS]int{0, 0, 0, 3, 3, 3, 3, 0}},
+		// Junction with three roads
+		{"L", Tile{11, [4]Area{AREA_ROAD, AREA_ROAD, AREA_ROAD, AREA_CITY}, false, false, 0x0, Meeple{-1, -1}}, [FIELD_SEGMENTS]int{0, 1, 1, 3, 3, 0, -1, -1}},
+		// City in one corner, road curve in the other one
+		{"O", Tile{14, [4]Area{AREA_CITY, AREA_ROAD, AREA_ROAD, AREA_CITY}, false, true, 0x1248, Meeple{-1, -1}}, [FIELD_SEGMENTS]int{-1, -1, 2, 3, 3, 2, -1, -1}},
+		// City through the tile splits the grass
+		{"F", Tile{5, [4]Area{AREA_CITY, AREA_GRASS, AREA_CITY, AREA_GRASS}, false, true, 0x0104, Meeple{-1, -1}}, [FIELD_SEGMENTS]int{-1, -1, 2, 2, -1, -1, 6, 6}},
+		// Two separate cities don't split the grass
+		{"H", Tile{7, [4]Area{AREA_GRASS, AREA_CITY, AREA_GRASS, AREA_CITY}, false, false, 0x0, Meeple{-1, -1}}, [FIELD_SEGMENTS]int{0, 0, -1, -1, 0, 0, -1, -1}},
+		// A road ending at a cloister doesn't split the grass
+		{"A", Tile{0, [4]Area{AREA_GRASS, AREA_ROAD, AREA_GRASS, AREA_GRASS}, true, false, 0x0, Meeple{-1, -1}}, [FIELD_SEGMENTS]int{0, 0, 0, 0, 0, 0, 0, 0}},
+		{"C", Tile{2, [4]Area{AREA_CITY, AREA_CITY, AREA_CITY, AREA_CITY}, false, true, 0x7BDE, Meeple{-1, -1}}, [FIELD_SEGMENTS]int{-1, -1, -1, -1, -1, -1, -1, -1}},
+	}
+
+	for _, c := range cases {
+		if fields := c.tile.fieldSegments(); fields != c.expected {
+			t.Errorf("Tile %v has wrong fields. %v != %v (expected)", c.name, fields, c.expected)
+		}
+	}
+}
+
+func TestFieldSegmentsRotate(t *testing.T) {
+	startTile, tiles := getTiles()
+	tiles = append(tiles, startTile)
+
+	for _, tile := range tiles {
+		fields := tile.fieldSegments()
+		rotated := rotateTile(tile)
+		rotatedFields := rotated.fieldSegments()
+
+		for a := 0; a < FIELD_SEGMENTS; a++ {
+			for b := 0; b < FIELD_SEGMENTS; b++ {
+				same := fields[a] != -1 && fields[a] == fields[b]
+				rotatedSame := rotatedFields[(a+2)%FIELD_SEGMENTS] != -1 && rotatedFields[(a+2)%FIELD_SEGMENTS] == rotatedFields[(b+2)%FIELD_SEGMENTS]
+				if same != rotatedSame {
+					t.Errorf("Fields of tile %v change when rotating: %v -> %v", tile, fields, rotatedFields)
+				}
+			}
+		}
+	}
+}
+
+func TestFarmPoints(t *testing.T) {
+	game := generateInitialBoard(3, 0)
+
+	startTile := game.board[Pos{0, 0}]
+	startTile.meeple = Meeple{SIDE_FIELD + 0, 1}
+	game.board[Pos{0, 0}] = startTile
+	// Closes the city of the start tile
+	game.board[Pos{0, -1}] = Tile{4, [4]Area{AREA_GRASS, AREA_CITY, AREA_GRASS, AREA_GRASS}, false, false, 0x0, Meeple{SIDE_FIELD + 0, 0}}
+	// Lower field of the start tile doesn't touch any city
+	game.board[Pos{1, 0}] = Tile{20, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_GRASS}, false, false, 0x0104, Meeple{SIDE_FIELD + 2, 2}}
+
+	//drawField(game.board)
+
+	expectedPoints := []int{3, 3, 0}
+	revMove := ReverseMove{}
+	game.updateFarmPoints(&revMove)
+
+	for i := range game.players {
+		if game.players[i].score != expectedPoints[i] {
+			t.Errorf("Player %v has wrong point count. %v != %v (expected)", i, game.players[i].score, expectedPoints[i])
+		}
+		if game.players[i].meeples != 7 {
+			t.Errorf("Player %v should get the farmer back but has %v meeples", i, game.players[i].meeples)
+		}
+	}
+	if meeples := getMeeplePositions(game.board); len(meeples) != 0 {
+		t.Errorf("All farmers should be removed from the board: %v", meeples)
+	}
+}
+
+func TestFarmPointsMajority(t *testing.T) {
+	game := generateInitialBoard(3, 0)
+
+	startTile := game.board[Pos{0, 0}]
+	startTile.meeple = Meeple{SIDE_FIELD + 0, 1}
+	game.board[Pos{0, 0}] = startTile
+	game.board[Pos{0, -1}] = Tile{4, [4]Area{AREA_GRASS, AREA_CITY, AREA_GRASS, AREA_GRASS}, false, false, 0x0, Meeple{-1, -1}}
+	// Both farmers are on the upper field of the start tile
+	game.board[Pos{-1, 0}] = Tile{20, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_GRASS}, false, false, 0x0104, Meeple{SIDE_FIELD + 5, 0}}
+	game.board[Pos{1, 0}] = Tile{20, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_GRASS}, false, false, 0x0104, Meeple{SIDE_FIELD + 0, 0}}
+
+	expectedPoints := []int{3, 0, 0}
+	scores, positions := calcFarmPoints(game.board, len(game.players))
+
+	for i, score := range scores {
+		if score != expectedPoints[i] {
+			t.Errorf("Player %v has wrong farm points. %v != %v (expected)", i, score, expectedPoints[i])
+		}
+	}
+	if len(positions) != 3 {
+		t.Errorf("Expected 3 farmers but found %v", len(positions))
+	}
+
+	// An open city doesn't give any points
+	delete(game.board, Pos{0, -1})
+	scores, _ = calcFarmPoints(game.board, len(game.players))
+	for i, score := range scores {
+		if score != 0 {
+			t.Errorf("Player %v should not get points for an open city but has %v", i, score)
+		}
+	}
+}
+
+func TestFarmerMoves(t *testing.T) {
+	game := generateInitialBoard(3, 0)
+	tile := Tile{3, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_CITY}, false, false, 0x0104, Meeple{-1, -1}}
+
+	moves := generatePossibleMoves(game.board, []Tile{tile}, game.openPlacements, game.players[0], len(game.players))
+
+	farmerMoves := 0
+	for _, m := range moves {
+		if !m.tile.meeple.isFarmer() {
+			continue
+		}
+		farmerMoves += 1
+		segment := m.tile.meeple.sideIndex - SIDE_FIELD
+		if m.tile.fieldSegments()[segment] != segment {
+			t.Errorf("Farmer should be placed on the first segment of a field: %v", m.tile)
+		}
+	}
+
+	// Every placement of the D tile has two fields
+	placements := 0
+	for _, m := range moves {
+		if m.tile.meeple.playerIndex == -1 {
+			placements += 1
+		}
+	}
+	if farmerMoves != 2*placements {
+		t.Errorf("Expected %v farmer moves but found %v", 2*placements, farmerMoves)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// Creates the file at path and writes its content with write. Used for all output files:
+// saved games, game records, images and the tournament statistics.
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	ROLLOUT_RANDOM = iota
+	// Uses selectBestMove() for every move of the rollout. Much slower, but a lot closer to real games.
+	ROLLOUT_GREEDY
+)
+
+type MCTSConfig struct {
+	// The UCT exploration constant. sqrt(2) is the usual choice for rewards between 0 and 1.
+	exploration float64
+	// The search stops after this many iterations or after the given duration. 0 means no limit,
+	// but at least one of them must be set.
+	iterations int
+	duration   time.Duration
+	rollout    int
+	// Rollouts stop after this many moves and the game is evaluated with the immediate points. 0 plays until the end.
+	rolloutDepth int
+}
+
+func defaultMCTSConfig() MCTSConfig {
+	return MCTSConfig{math.Sqrt2, 1000, 0, ROLLOUT_RANDOM, 0}
+}
+
+// The options of "mcts:key=value" agents, see newAgent().
+func (config *MCTSConfig) options() map[string]func(string) error {
+	return map[string]func(string) error{
+		"exploration": floatOption(&config.exploration),
+		"iterations":  intOption(&config.iterations),
+		"duration": func(value string) (err error) {
+			config.duration, err = time.ParseDuration(value)
+			return
+		},
+		"rollout":      enumOption(&config.rollout, "random", "greedy"),
+		"rolloutdepth": intOption(&config.rolloutDepth),
+	}
+}
+
+// A node where a player has to decide on a move for the drawn tile.
+type mctsDecision struct {
+	player   int
+	moves    []Move
+	children []*mctsMove
+	visits   int
+}
+
+// A node for a move that was made. The next tile is drawn afterwards, so the children
+// are chance nodes: one decision for every tile type that might be drawn.
+type mctsMove struct {
+	move   Move
+	player int
+	visits int
+	// Accumulated reward from the point of view of the player, that made the move
+	value float64
+	draws map[int]*mctsDecision
+}
+
+func newMCTSDecision(player int, moves []Move) *mctsDecision {
+	return &mctsDecision{player, moves, make([]*mctsMove, len(moves), len(moves)), 0}
+}
+
+// Selects the next child with UCT. Unvisited children are expanded first.
+func (d *mctsDecision) selectChild(exploration float64, r *rand.Rand) (int, bool) {
+	var unvisited []int
+	for i, c := range d.children {
+		if c == nil {
+			unvisited = append(unvisited, i)
+		}
+	}
+	if len(unvisited) > 0 {
+		return unvisited[r.Intn(len(unvisited))], true
+	}
+
+	best, bestValue := 0, math.Inf(-1)
+	logVisits := math.Log(float64(d.visits))
+	for i, c := range d.children {
+		value := c.value/float64(c.visits) + exploration*math.Sqrt(logVisits/float64(c.visits))
+		if value > bestValue {
+			best, bestValue = i, value
+		}
+	}
+	return best, false
+}
+
+// Draws the next placeable tile from the deck. Tiles that can't be placed anywhere are discarded,
+// as the official rules say. Returns the moves for the tile and the new deck.
+func (game *GameState) drawPlaceableTile(deck []Tile, player Player) (Tile, []Move, []Tile) {
+	for len(deck) > 0 {
+		tile := deck[0]
+		deck = deck[1:]
+		if moves := generatePossibleMoves(game.board, []Tile{tile}, game.openPlacements, player, len(game.players)); len(moves) > 0 {
+			return tile, moves, deck
+		}
+	}
+	return Tile{}, nil, deck
+}
+
+// The reward of every player for the final (or estimated) scores. A win is worth more than 0.5, a loss less.
+// The score difference to the best other player is included, so bigger wins are better.
+func mctsRewards(scores []int) []float64 {
+	rewards := make([]float64, len(scores), len(scores))
+	for p, score := range scores {
+		bestOther := math.MinInt32
+		for other, otherScore := range scores {
+			if other != p && otherScore > bestOther {
+				bestOther = otherScore
+			}
+		}
+		if len(scores) == 1 {
+			bestOther = 0
+		}
+		rewards[p] = 0.5 + 0.5*math.Tanh(float64(score-bestOther)/20.0)
+	}
+	return rewards
+}
+
+// Plays the rest of the (determinized) deck and returns the rewards. All moves stay on the move stack
+// and are counted in *madeMoves, so they can be reversed.
+func (game *GameState) mctsRollout(deck []Tile, player int, config MCTSConfig, madeMoves *int) []float64 {
+	for depth := 0; len(deck) > 0 && (config.rolloutDepth == 0 || depth < config.rolloutDepth); depth++ {
+		var moves []Move
+		_, moves, deck = game.drawPlaceableTile(deck, game.players[player])
+		if len(moves) == 0 {
+			break
+		}
+
+		move := moves[game.rng.Intn(len(moves))]
+		if config.rollout == ROLLOUT_GREEDY {
+			move = game.selectBestMove(moves, game.players[player])
+		}
+		game.makeMove(move)
+		*madeMoves += 1
+		player = (player + 1) % len(game.players)
+	}
+
+	scores := make([]int, len(game.players), len(game.players))
+	if len(deck) == 0 {
+		game.finalizeGame()
+		*madeMoves += 1
+	} else {
+		game.updateImmediatePoints(&scores)
+	}
+	for p := range scores {
+		scores[p] += game.players[p].score
+	}
+	return mctsRewards(scores)
+}
+
+// One iteration: selection, expansion, rollout and backpropagation. The deck is already shuffled.
+func (game *GameState) mctsIteration(root *mctsDecision, deck []Tile, config MCTSConfig) {
+	var path []*mctsMove
+	decisions := []*mctsDecision{root}
+	madeMoves := 0
+	var rewards []float64
+
+	decision := root
+	for {
+		i, expanded := decision.selectChild(config.exploration, game.rng)
+		if expanded {
+			decision.children[i] = &mctsMove{decision.moves[i], decision.player, 0, 0, map[int]*mctsDecision{}}
+		}
+		node := decision.children[i]
+		path = append(path, node)
+
+		game.makeMove(node.move)
+		madeMoves += 1
+		next := (decision.player + 1) % len(game.players)
+
+		if expanded {
+			rewards = game.mctsRollout(deck, next, config, &madeMoves)
+			break
+		}
+
+		var tile Tile
+		var moves []Move
+		tile, moves, deck = game.drawPlaceableTile(deck, game.players[next])
+		if len(moves) == 0 {
+			game.finalizeGame()
+			madeMoves += 1
+			scores := make([]int, len(game.players), len(game.players))
+			for p := range scores {
+				scores[p] = game.players[p].score
+			}
+			rewards = mctsRewards(scores)
+			break
+		}
+
+		// Chance node: every tile type gets its own decision node
+		if _, ok := node.draws[tile.id]; !ok {
+			node.draws[tile.id] = newMCTSDecision(next, moves)
+		}
+		decision = node.draws[tile.id]
+		decisions = append(decisions, decision)
+	}
+
+	for i := 0; i < madeMoves; i++ {
+		game.reverseLastMove()
+	}
+
+	for _, d := range decisions {
+		d.visits += 1
+	}
+	for _, node := range path {
+		node.visits += 1
+		node.value += rewards[node.player]
+	}
+}
+
+// Selects a move with Monte Carlo Tree Search (UCT). The random tile draw is handled by determinization:
+// every iteration plays with a freshly shuffled copy of the remaining tiles. Tile draws are chance nodes,
+// so every tile type gets its own subtree. Nothing is copied, all moves are made and reversed on the game itself.
+// remaining are the tiles that will be drawn after this move.
+func (game *GameState) selectMCTSMove(moves []Move, player Player, remaining []Tile, config MCTSConfig) Move {
+	if len(moves) == 1 {
+		return moves[0]
+	}
+
+	if config.iterations <= 0 && config.duration <= 0 {
+		config.iterations = defaultMCTSConfig().iterations
+	}
+
+	root := newMCTSDecision(player.index, moves)
+	deck := make([]Tile, len(remaining), len(remaining))
+	start := time.Now()
+
+	for i := 0; ; i++ {
+		if config.iterations > 0 && i >= config.iterations {
+			break
+		}
+		if config.duration > 0 && time.Since(start) >= config.duration {
+			break
+		}
+
+		copy(deck, remaining)
+		game.rng.Shuffle(len(deck), func(i, j int) { deck[i], deck[j] = deck[j], deck[i] })
+		game.mctsIteration(root, deck, config)
+	}
+
+	best := 0
+	for i, c := range root.children {
+		if c != nil && (root.children[best] == nil || c.visits > root.children[best].visits) {
+			best = i
+		}
+	}
+	return moves[best]
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Plays some random moves and returns the index of the next tile.
+func playRandomMoves(game *GameState, count int) int {
+	i := 0
+	for moves := 0; moves < count && i < len(game.tiles); i++ {
+		player := game.players[moves%len(game.players)]
+		possible := generatePossibleMoves(game.board, []Tile{game.tiles[i]}, game.openPlacements, player, len(game.players))
+		if len(possible) > 0 {
+			game.makeMove(possible[rand.Intn(len(possible))])
+			moves += 1
+		}
+	}
+	return i
+}
+
+func TestMCTSKeepsGameState(t *testing.T) {
+	game := generateInitialBoard(3, 0)
+	i := playRandomMoves(&game, 12)
+
+	player := game.players[0]
+	moves := generatePossibleMoves(game.board, []Tile{game.tiles[i]}, game.openPlacements, player, len(game.players))
+
+	boardSize, moveCount := len(game.board), len(game.lastMoves)
+	players := append([]Player{}, game.players...)
+
+	config := defaultMCTSConfig()
+	config.iterations = 200
+	move := game.selectMCTSMove(moves, player, game.tiles[i+1:], config)
+
+	found := false
+	for _, m := range moves {
+		if m == move {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("MCTS selected a move that is not possible: %v", move)
+	}
+	if len(game.board) != boardSize || len(game.lastMoves) != moveCount {
+		t.Errorf("MCTS changed the game. Board: %v != %v, moves: %v != %v", len(game.board), boardSize, len(game.lastMoves), moveCount)
+	}
+	for p := range players {
+		if game.players[p] != players[p] {
+			t.Errorf("MCTS changed player %v: %v != %v", p, game.players[p], players[p])
+		}
+	}
+}
+
+func TestMCTSLastTile(t *testing.T) {
+	game := generateInitialBoard(3, 0)
+	i := playRandomMoves(&game, 20)
+
+	player := game.players[1]
+	moves := generatePossibleMoves(game.board, []Tile{game.tiles[i]}, game.openPlacements, player, len(game.players))
+
+	// With the last tile, the reward of every move is known exactly
+	rewardOf := func(move Move) float64 {
+		game.makeMove(move)
+		game.finalizeGame()
+		scores := make([]int, len(game.players), len(game.players))
+		for p := range scores {
+			scores[p] = game.players[p].score
+		}
+		game.reverseLastMove()
+		game.reverseLastMove()
+		return mctsRewards(scores)[player.index]
+	}
+
+	bestReward := 0.0
+	for _, m := range moves {
+		if r := rewardOf(m); r > bestReward {
+			bestReward = r
+		}
+	}
+
+	config := defaultMCTSConfig()
+	config.iterations = 20 * len(moves)
+	move := game.selectMCTSMove(moves, player, nil, config)
+
+	if r := rewardOf(move); r != bestReward {
+		t.Errorf("MCTS should find the best last move. Reward %v != %v (expected)", r, bestReward)
+	}
+}
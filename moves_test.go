@@ -0,0 +1,260 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Returns all moves at the position, grouped by the side of the placed meeple (-1 == no meeple).
+func meepleSidesAt(moves []Move, pos Pos, sides [4]Area) map[int]bool {
+	meepleSides := map[int]bool{}
+	for _, m := range moves {
+		if m.pos == pos && m.tile.sides == sides {
+			if m.tile.meeple.playerIndex == -1 {
+				meepleSides[-1] = true
+			} else {
+				meepleSides[m.tile.meeple.sideIndex] = true
+			}
+		}
+	}
+	return meepleSides
+}
+
+func TestOccupiedRoad(t *testing.T) {
+	game := generateInitialBoard(3, 0)
+
+	conns := connectionsToUint16([]Pos{Pos{0, 2}})
+	game.board[Pos{1, 0}] = Tile{20, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_GRASS}, false, false, conns, Meeple{SIDE_RIGHT, 1}}
+
+	tile := Tile{20, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_GRASS}, false, false, conns, Meeple{-1, -1}}
+	moves := generatePossibleMoves(game.board, []Tile{tile}, map[Pos]bool{Pos{-1, 0}: true}, game.players[0], len(game.players))
+	sides := meepleSidesAt(moves, Pos{-1, 0}, tile.sides)
+
+	if !sides[-1] {
+		t.Errorf("The tile should be placeable without a meeple")
+	}
+	if sides[SIDE_LEFT] || sides[SIDE_RIGHT] {
+		t.Errorf("The road is already occupied by player 1. No meeple should be placed on it: %v", sides)
+	}
+	if !sides[SIDE_FIELD+0] || !sides[SIDE_FIELD+1] {
+		t.Errorf("Both fields of the tile are still free: %v", sides)
+	}
+
+	// The road on the other tile is a different one
+	tile = Tile{21, [4]Area{AREA_GRASS, AREA_ROAD, AREA_ROAD, AREA_GRASS}, false, false, connectionsToUint16([]Pos{Pos{1, 2}}), Meeple{-1, -1}}
+	moves = generatePossibleMoves(game.board, []Tile{tile}, map[Pos]bool{Pos{0, 1}: true}, game.players[0], len(game.players))
+	if sides := meepleSidesAt(moves, Pos{0, 1}, tile.sides); !sides[SIDE_DOWN] || !sides[SIDE_RIGHT] {
+		t.Errorf("A new road should be free: %v", sides)
+	}
+}
+
+func TestOccupiedCity(t *testing.T) {
+	game := generateInitialBoard(3, 0)
+
+	conns := connectionsToUint16([]Pos{Pos{0, 1}, Pos{0, 2}, Pos{0, 3}, Pos{1, 2}, Pos{1, 3}, Pos{2, 3}})
+	game.board[Pos{0, -1}] = Tile{2, [4]Area{AREA_CITY, AREA_CITY, AREA_CITY, AREA_CITY}, false, true, conns, Meeple{SIDE_UP, 2}}
+	conns = connectionsToUint16([]Pos{Pos{0, 2}})
+	game.board[Pos{-1, -1}] = Tile{6, [4]Area{AREA_CITY, AREA_GRASS, AREA_CITY, AREA_GRASS}, false, false, conns, Meeple{-1, -1}}
+
+	tile := Tile{4, [4]Area{AREA_GRASS, AREA_GRASS, AREA_CITY, AREA_GRASS}, false, false, 0x0, Meeple{-1, -1}}
+	moves := generatePossibleMoves(game.board, []Tile{tile}, map[Pos]bool{Pos{-2, -1}: true}, game.players[0], len(game.players))
+	sides := meepleSidesAt(moves, Pos{-2, -1}, tile.sides)
+
+	if !sides[-1] {
+		t.Errorf("The tile should be placeable without a meeple")
+	}
+	if sides[SIDE_RIGHT] {
+		t.Errorf("The city is already occupied by player 2. No meeple should be placed on it: %v", sides)
+	}
+}
+
+func TestOccupiedMergedRoads(t *testing.T) {
+	game := generateInitialBoard(3, 0)
+
+	// Two separate roads, only the left one is occupied
+	conns := connectionsToUint16([]Pos{Pos{0, 2}})
+	game.board[Pos{-1, 0}] = Tile{20, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_GRASS}, false, false, conns, Meeple{SIDE_LEFT, 1}}
+	game.board[Pos{-2, 1}] = Tile{21, [4]Area{AREA_GRASS, AREA_GRASS, AREA_ROAD, AREA_ROAD}, false, false, connectionsToUint16([]Pos{Pos{2, 3}}), Meeple{-1, -1}}
+
+	// Connects both roads
+	tile := Tile{21, [4]Area{AREA_GRASS, AREA_ROAD, AREA_ROAD, AREA_GRASS}, false, false, connectionsToUint16([]Pos{Pos{1, 2}}), Meeple{-1, -1}}
+	moves := generatePossibleMoves(game.board, []Tile{tile}, map[Pos]bool{Pos{-2, 0}: true}, game.players[0], len(game.players))
+	sides := meepleSidesAt(moves, Pos{-2, 0}, tile.sides)
+
+	if !sides[-1] {
+		t.Errorf("Occupied roads can still be merged by a tile without meeple")
+	}
+	if sides[SIDE_RIGHT] || sides[SIDE_DOWN] {
+		t.Errorf("The merged road is occupied. No meeple should be placed on it: %v", sides)
+	}
+
+	// Without the meeple on the left road, the merged road is free
+	tmp := game.board[Pos{-1, 0}]
+	tmp.meeple = Meeple{-1, -1}
+	game.board[Pos{-1, 0}] = tmp
+
+	moves = generatePossibleMoves(game.board, []Tile{tile}, map[Pos]bool{Pos{-2, 0}: true}, game.players[0], len(game.players))
+	if sides := meepleSidesAt(moves, Pos{-2, 0}, tile.sides); !sides[SIDE_RIGHT] || !sides[SIDE_DOWN] {
+		t.Errorf("The merged road is free: %v", sides)
+	}
+
+	if _, ok := game.board[Pos{-2, 0}]; ok {
+		t.Errorf("Generating moves must not leave the tile on the board")
+	}
+}
+
+func TestOccupiedField(t *testing.T) {
+	game := generateInitialBoard(3, 0)
+
+	startTile := game.board[Pos{0, 0}]
+	startTile.meeple = Meeple{SIDE_FIELD + 1, 0}
+	game.board[Pos{0, 0}] = startTile
+
+	conns := connectionsToUint16([]Pos{Pos{0, 2}})
+	tile := Tile{20, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_GRASS}, false, false, conns, Meeple{-1, -1}}
+	moves := generatePossibleMoves(game.board, []Tile{tile}, map[Pos]bool{Pos{1, 0}: true}, game.players[1], len(game.players))
+	sides := meepleSidesAt(moves, Pos{1, 0}, tile.sides)
+
+	// The lower field continues the lower field of the start tile
+	if sides[SIDE_FIELD+1] {
+		t.Errorf("The lower field is already occupied by player 0: %v", sides)
+	}
+	if !sides[SIDE_FIELD+0] {
+		t.Errorf("The upper field should be free: %v", sides)
+	}
+}
+
+// The road runs through both road pairs of a TierI tile. The meeple can only be found by searching
+// the TierI tile a second time, when the road comes back around the loop.
+func TestOccupiedLoopingRoad(t *testing.T) {
+	players := generateInitialBoard(2, 0).players
+
+	horizontal := Tile{20, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_GRASS}, false, false, connectionsToUint16([]Pos{Pos{0, 2}}), Meeple{-1, -1}}
+	vertical := Tile{20, [4]Area{AREA_GRASS, AREA_ROAD, AREA_GRASS, AREA_ROAD}, false, false, connectionsToUint16([]Pos{Pos{1, 3}}), Meeple{-1, -1}}
+	occupied := horizontal
+	occupied.meeple = Meeple{SIDE_RIGHT, 1}
+
+	board := map[Pos]Tile{
+		Pos{0, 0}:   Tile{32, [4]Area{AREA_ROAD, AREA_ROAD, AREA_ROAD, AREA_ROAD}, false, false, connectionsToUint16([]Pos{Pos{0, 3}, Pos{1, 2}}), Meeple{-1, -1}},
+		Pos{0, -1}:  Tile{21, [4]Area{AREA_ROAD, AREA_ROAD, AREA_GRASS, AREA_GRASS}, false, false, connectionsToUint16([]Pos{Pos{0, 1}}), Meeple{-1, -1}},
+		Pos{-1, -1}: horizontal,
+		Pos{-2, -1}: Tile{21, [4]Area{AREA_GRASS, AREA_ROAD, AREA_ROAD, AREA_GRASS}, false, false, connectionsToUint16([]Pos{Pos{1, 2}}), Meeple{-1, -1}},
+		Pos{-2, 0}:  vertical,
+		Pos{-2, 1}:  Tile{21, [4]Area{AREA_GRASS, AREA_GRASS, AREA_ROAD, AREA_ROAD}, false, false, connectionsToUint16([]Pos{Pos{2, 3}}), Meeple{-1, -1}},
+		Pos{-1, 1}:  horizontal,
+		Pos{0, 1}:   Tile{21, [4]Area{AREA_ROAD, AREA_GRASS, AREA_GRASS, AREA_ROAD}, false, false, connectionsToUint16([]Pos{Pos{0, 3}}), Meeple{-1, -1}},
+		Pos{1, 0}:   occupied,
+	}
+
+	// A cloister with its road ending at the left of the TierI tile. It only fits with the road to the right
+	tile := Tile{0, [4]Area{AREA_GRASS, AREA_ROAD, AREA_GRASS, AREA_GRASS}, true, false, 0x0, Meeple{-1, -1}}
+	moves := generatePossibleMoves(board, []Tile{tile}, map[Pos]bool{Pos{-1, 0}: true}, players[0], len(players))
+	sides := meepleSidesAt(moves, Pos{-1, 0}, [4]Area{AREA_GRASS, AREA_GRASS, AREA_ROAD, AREA_GRASS})
+
+	if !sides[-1] || !sides[SIDE_CENTER] {
+		t.Errorf("The tile should be placeable without a meeple and with a monk: %v", sides)
+	}
+	if sides[SIDE_RIGHT] {
+		t.Errorf("The looping road is already occupied by player 1. No meeple should be placed on it: %v", sides)
+	}
+	if _, ok := board[Pos{-1, 0}]; ok || len(board) != 9 {
+		t.Errorf("Generating moves must not change the board")
+	}
+}
+
+func sameTiles(a, b []Tile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// makeMove() followed by reverseLastMove() must leave the deck unchanged, no matter which tile of the deck was drawn.
+func TestReverseMoveRestoresDeck(t *testing.T) {
+	for g := 0; g < 5; g++ {
+		game := generateInitialBoard(2+g%3, int64(g))
+
+		for len(game.tiles) > 1 {
+			game.drawnTile = rand.Intn(len(game.tiles))
+			tile := game.tiles[game.drawnTile]
+			moves := generatePossibleMoves(game.board, []Tile{tile}, game.openPlacements, game.players[game.currentPlayer], len(game.players))
+			if len(moves) == 0 {
+				game.tiles = append(game.tiles[:game.drawnTile:game.drawnTile], game.tiles[game.drawnTile+1:]...)
+				continue
+			}
+
+			deck := append([]Tile{}, game.tiles...)
+			drawn := game.drawnTile
+			for i := 0; i < 3; i++ {
+				game.makeMove(moves[rand.Intn(len(moves))])
+				if len(game.tiles) != len(deck)-1 {
+					t.Fatalf("The drawn tile was not removed from the deck")
+				}
+				game.reverseLastMove()
+				if !sameTiles(game.tiles, deck) || game.drawnTile != drawn {
+					t.Fatalf("The deck changed after reversing the move of tile %v at index %v", tile, drawn)
+				}
+			}
+
+			game.makeMove(moves[rand.Intn(len(moves))])
+			if !sameTiles(game.tiles, append(deck[:drawn:drawn], deck[drawn+1:]...)) {
+				t.Fatalf("The wrong tile was removed from the deck")
+			}
+		}
+	}
+}
+
+func TestMakeMoveChecksDrawnTile(t *testing.T) {
+	game := generateInitialBoard(2, 0)
+	tile, moves, _ := game.drawTile()
+
+	var other Tile
+	for _, d := range game.tiles {
+		if d.id != tile.id {
+			other = d
+			break
+		}
+	}
+	move := moves[0]
+	move.tile.id = other.id
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Placing tile %v while tile %v is drawn should panic", other.id, tile.id)
+		}
+		if len(game.board) != 1 || len(game.lastMoves) != 0 {
+			t.Errorf("The game changed before the wrong tile was noticed")
+		}
+	}()
+	game.makeMove(move)
+}
+
+// Symmetric tiles look the same in several rotations, which must not give the same move more than once.
+func TestNoDuplicateMoves(t *testing.T) {
+	game := generateInitialBoard(2, 0)
+	rotations := map[string]int{"C": 1, "X": 1, "U": 2, "V": 4}
+	for _, tile := range game.tileSet.types {
+		name := game.tileSet.tileName(tile.id)
+		// Nothing next to the position, so every rotation fits
+		moves := generatePossibleMoves(map[Pos]Tile{}, []Tile{tile}, map[Pos]bool{Pos{0, 0}: true}, game.players[0], len(game.players))
+		seen := map[Move]bool{}
+		withoutMeeple := 0
+		for _, m := range moves {
+			if seen[m] {
+				t.Errorf("%v: the move %v is listed more than once", name, m)
+			}
+			seen[m] = true
+			if m.tile.meeple.playerIndex == -1 {
+				withoutMeeple++
+			}
+		}
+		if expected, ok := rotations[name]; ok && withoutMeeple != expected {
+			t.Errorf("%v: expected %v rotations, got %v", name, expected, withoutMeeple)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A move is written as "<tile> r<rotation> @<x>,<y>" plus " M:<side>" if a meeple is placed, e.g. "D r1 @-1,0 M:up".
+// The tile is the name of the tile type in the tile set of the game and the rotation is the number of
+// rotations by rotateTile(). A meeple is placed on one of the sides left, down, right and up, on the
+// cloister in the center or as a farmer on the field segments f0 - f7 (see fields.go).
+var g_meepleSideNames = []string{"left", "down", "right", "up", "center"}
+
+func meepleSideName(side int) string {
+	if side >= SIDE_FIELD {
+		return fmt.Sprintf("f%v", side-SIDE_FIELD)
+	}
+	return g_meepleSideNames[side]
+}
+
+func parseMeepleSide(name string) (int, bool) {
+	for i, n := range g_meepleSideNames {
+		if n == name {
+			return i, true
+		}
+	}
+	if strings.HasPrefix(name, "f") {
+		if segment, err := strconv.Atoi(name[1:]); err == nil && segment >= 0 && segment < FIELD_SEGMENTS {
+			return SIDE_FIELD + segment, true
+		}
+	}
+	return -1, false
+}
+
+// Returns the notation of the move.
+func (game GameState) moveNotation(move Move) (string, error) {
+	rotation, err := game.tileSet.rotation(move.tile)
+	if err != nil {
+		return "", err
+	}
+	notation := fmt.Sprintf("%v r%v @%v,%v", game.tileSet.tileName(move.tile.id), rotation, move.pos.x, move.pos.y)
+	if move.tile.meeple.playerIndex != -1 {
+		notation += " M:" + meepleSideName(move.tile.meeple.sideIndex)
+	}
+	return notation, nil
+}
+
+// Resolves the notation to a legal move of the current player with the drawn tile.
+func (game GameState) parseMove(notation string) (Move, error) {
+	fields := strings.Fields(notation)
+	if len(fields) < 3 || len(fields) > 4 {
+		return Move{}, fmt.Errorf("invalid move %q, expected something like \"D r1 @-1,0 M:up\"", notation)
+	}
+	if game.drawnTile == -1 {
+		return Move{}, fmt.Errorf("move %q: no tile was drawn", notation)
+	}
+
+	drawn := game.tiles[game.drawnTile]
+	if name := game.tileSet.tileName(drawn.id); name != fields[0] {
+		return Move{}, fmt.Errorf("move %q: the drawn tile is %v", notation, name)
+	}
+
+	rotation, err := strconv.Atoi(strings.TrimPrefix(fields[1], "r"))
+	if !strings.HasPrefix(fields[1], "r") || err != nil {
+		return Move{}, fmt.Errorf("move %q: invalid rotation %q", notation, fields[1])
+	}
+	tile, err := game.tileSet.rotatedTile(fields[0], rotation)
+	if err != nil {
+		return Move{}, fmt.Errorf("move %q: %v", notation, err)
+	}
+
+	coords := strings.Split(strings.TrimPrefix(fields[2], "@"), ",")
+	if !strings.HasPrefix(fields[2], "@") || len(coords) != 2 {
+		return Move{}, fmt.Errorf("move %q: invalid position %q", notation, fields[2])
+	}
+	x, errX := strconv.Atoi(coords[0])
+	y, errY := strconv.Atoi(coords[1])
+	if errX != nil || errY != nil {
+		return Move{}, fmt.Errorf("move %q: invalid position %q", notation, fields[2])
+	}
+
+	player := game.players[game.currentPlayer]
+	if len(fields) == 4 {
+		side, ok := parseMeepleSide(strings.TrimPrefix(fields[3], "M:"))
+		if !strings.HasPrefix(fields[3], "M:") || !ok {
+			return Move{}, fmt.Errorf("move %q: invalid meeple %q", notation, fields[3])
+		}
+		// A farmer can be placed on any segment of the field
+		if side >= SIDE_FIELD {
+			side = SIDE_FIELD + tile.fieldSegments()[side-SIDE_FIELD]
+			if side < SIDE_FIELD {
+				return Move{}, fmt.Errorf("move %q: %v is no field", notation, fields[3])
+			}
+		}
+		tile.meeple = Meeple{side, player.index}
+	}
+
+	move := Move{tile, Pos{x, y}}
+	for _, m := range generatePossibleMoves(game.board, []Tile{drawn}, game.openPlacements, player, len(game.players)) {
+		if m == move {
+			return m, nil
+		}
+	}
+	return Move{}, fmt.Errorf("move %q is not possible", notation)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMoveNotation(t *testing.T) {
+	game := generateInitialBoard(3, 11)
+	for {
+		_, moves, ok := game.drawTile()
+		if !ok {
+			break
+		}
+		for _, move := range moves {
+			notation, err := game.moveNotation(move)
+			if err != nil {
+				t.Fatalf("No notation for %v: %v", move, err)
+			}
+			parsed, err := game.parseMove(notation)
+			if err != nil {
+				t.Fatalf("Parsing %q failed: %v", notation, err)
+			}
+			if parsed != move {
+				t.Fatalf("%q was parsed to %v instead of %v", notation, parsed, move)
+			}
+		}
+		game.makeMove(moves[game.rng.Intn(len(moves))])
+	}
+}
+
+func TestParseInvalidMoves(t *testing.T) {
+	game := generateInitialBoard(2, 0)
+	game.tiles[0], _ = game.tileSet.rotatedTile("V", 0)
+	game.drawTile()
+
+	cases := []struct {
+		notation string
+		error    string
+	}{
+		{"V r0", "invalid move"},
+		{"U r0 @1,0", "drawn tile is V"},
+		{"V 0 @1,0", "invalid rotation"},
+		{"V r5 @1,0", "invalid rotation"},
+		{"V r0 1,0", "invalid position"},
+		{"V r0 @1;0", "invalid position"},
+		{"V r0 @5,5", "not possible"},
+		{"V r0 @1,0 M:middle", "invalid meeple"},
+		{"V r0 @1,0 M:center", "not possible"},
+	}
+	for _, c := range cases {
+		_, err := game.parseMove(c.notation)
+		if err == nil || !strings.Contains(err.Error(), c.error) {
+			t.Errorf("%q: expected an error containing %q, got %v", c.notation, c.error, err)
+		}
+	}
+
+	// Any segment of a field is fine for a farmer
+	_, moves, _ := game.drawTile()
+	for _, m := range moves {
+		if !m.tile.meeple.isFarmer() {
+			continue
+		}
+		fields := m.tile.fieldSegments()
+		for segment, field := range fields {
+			if field != m.tile.meeple.sideIndex-SIDE_FIELD {
+				continue
+			}
+			notation, _ := game.moveNotation(m)
+			notation = notation[:strings.Index(notation, "M:")+2] + meepleSideName(SIDE_FIELD+segment)
+			if parsed, err := game.parseMove(notation); err != nil || parsed != m {
+				t.Errorf("%q should be the farmer move %v, got %v (%v)", notation, m, parsed, err)
+			}
+		}
+	}
+}
+
+func TestGameRecord(t *testing.T) {
+	game := generateInitialBoard(3, 21)
+	runGame(&game, []Agent{RandomAgent{}, GreedyAgent{}, FirstMoveAgent{}})
+
+	rec, err := game.record([]string{"random", "greedy", "first"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := rec.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	text := "; An annotated game\n" + buf.String()
+	text = strings.Replace(text, "\n3. ", "\n;Risky, the road might never be closed\n; Greedy answers\n3. ", 1)
+	text += "; The end\n"
+
+	parsed, err := parseRecord(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("Parsing the record failed: %v\n%v", err, text)
+	}
+	if players, _ := parsed.header("Players"); players != "random,greedy,first" {
+		t.Errorf("Wrong players header: %q", players)
+	}
+
+	expectedComments := map[int][]string{0: {" An annotated game"}, 2: {"Risky, the road might never be closed", " Greedy answers"}, len(parsed.moves): {" The end"}}
+	if !reflect.DeepEqual(parsed.comments, expectedComments) {
+		t.Errorf("Wrong comments: %q", parsed.comments)
+	}
+	var written bytes.Buffer
+	if err := parsed.write(&written); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := parseRecord(&written); err != nil || !reflect.DeepEqual(again, parsed) {
+		t.Errorf("The record changed after writing and parsing it again (%v)", err)
+	}
+
+	replayed, err := parsed.replay(nil)
+	if err != nil {
+		t.Fatalf("Replaying the record failed: %v", err)
+	}
+	if diffs := game.Diff(replayed); len(diffs) > 0 {
+		t.Errorf("The replayed game differs: %v", diffs)
+	}
+
+	parsed.moves[3], parsed.moves[4] = parsed.moves[4], parsed.moves[3]
+	if _, err := parsed.replay(nil); err == nil || !strings.Contains(err.Error(), "move 4") {
+		t.Errorf("Expected the replay to fail at move 4, got %v", err)
+	}
+}
+
+func TestRecordUnknownTileSet(t *testing.T) {
+	rec, err := parseRecord(strings.NewReader("[TileSet \"river\"]\n[Seed \"1\"]\n[Players \"first,first\"]\n\n1. D r0 @1,0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.replay(nil); err == nil || !strings.Contains(err.Error(), "river") {
+		t.Errorf("Expected an error for the unknown tile set, got %v", err)
+	}
+}
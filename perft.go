@@ -0,0 +1,39 @@
+package main
+
+// Counts the leaf nodes of the move tree up to the given depth (in moves, not rounds), like perft in chess engines.
+// The tiles are drawn in deck order. Tiles that can't be placed are skipped for the rest of the
+// branch, just like drawTile() discards them, but without changing the deck.
+// Used to verify move generation and make/reverse against known counts.
+func (game *GameState) perft(depth int) int {
+	drawnTile := game.drawnTile
+	count := game.perftFrom(depth, 0)
+	game.drawnTile = drawnTile
+	return count
+}
+
+func (game *GameState) perftFrom(depth int, first int) int {
+	if depth == 0 {
+		return 1
+	}
+
+	for i := first; i < len(game.tiles); i++ {
+		moves := generatePossibleMoves(game.board, []Tile{game.tiles[i]}, game.openPlacements, game.players[game.currentPlayer], len(game.players))
+		if len(moves) == 0 {
+			continue
+		}
+		if depth == 1 {
+			return len(moves)
+		}
+
+		count := 0
+		for _, move := range moves {
+			game.drawnTile = i
+			game.makeMove(move)
+			count += game.perftFrom(depth-1, i)
+			game.reverseLastMove()
+		}
+		return count
+	}
+	// The deck is empty. The game is over
+	return 1
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+)
+
+// Known leaf counts for generateInitialBoard(2, seed). They only change if the move generation changes.
+var g_perftCounts = []struct {
+	seed   int64
+	counts []int
+}{
+	{1, []int{20, 375, 9881}},
+	{2, []int{16, 526, 3162, 82806}},
+	{3, []int{30, 486, 23946}},
+}
+
+func TestPerft(t *testing.T) {
+	for _, p := range g_perftCounts {
+		game := generateInitialBoard(2, p.seed)
+		original := game.copy()
+		for i, expected := range p.counts {
+			if count := game.perft(i + 1); count != expected {
+				t.Errorf("perft(%v) of seed %v: %v != %v (expected)", i+1, p.seed, count, expected)
+			}
+		}
+		if diffs := original.Diff(game); len(diffs) > 0 {
+			t.Errorf("perft changed the game state: %v", diffs)
+		}
+	}
+}
+
+// A reproducible position in the middle of the game.
+func benchmarkGame(moveCount int) GameState {
+	game := generateInitialBoard(3, 7)
+	for i := 0; i < moveCount; i++ {
+		_, moves, ok := game.drawTile()
+		if !ok {
+			break
+		}
+		game.makeMove(moves[game.rng.Intn(len(moves))])
+	}
+	game.drawTile()
+	return game
+}
+
+func BenchmarkPerft3(b *testing.B) {
+	game := generateInitialBoard(2, 1)
+	for i := 0; i < b.N; i++ {
+		game.perft(3)
+	}
+}
+
+func BenchmarkGeneratePossibleMoves(b *testing.B) {
+	game := benchmarkGame(30)
+	tiles := []Tile{game.tiles[game.drawnTile]}
+	player := game.players[game.currentPlayer]
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		generatePossibleMoves(game.board, tiles, game.openPlacements, player, len(game.players))
+	}
+}
+
+func BenchmarkPlacementPossible(b *testing.B) {
+	game := benchmarkGame(30)
+	tile := game.tiles[game.drawnTile]
+	positions := sortedPositions(game.openPlacements)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		placementPossible(game.board, tile, positions[i%len(positions)])
+	}
+}
+
+func BenchmarkUpdateFinalPoints(b *testing.B) {
+	game := benchmarkGame(30)
+	_, moves, _ := game.drawTile()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		move := moves[i%len(moves)]
+		revMove := ReverseMove{deckIndex: -1}
+		placeTile(&game, move.tile, move.pos, &revMove)
+		game.updateFinalPoints(move.pos, &revMove)
+		game.lastMoves = append(game.lastMoves, revMove)
+		game.reverseLastMove()
+	}
+}
+
+func BenchmarkUpdateImmediatePoints(b *testing.B) {
+	game := benchmarkGame(30)
+	scores := make([]int, len(game.players))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		game.updateImmediatePoints(&scores)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A game of humans against agents on the terminal. A human picks the position of the drawn tile from the
+// numbered open positions on the board, then the rotation and the meeple, or enters a whole move in
+// notation (see notation.go). undo takes back the last own move and the moves of the agents since,
+// hint shows the choice of selectBestMove().
+type interactiveGame struct {
+	game *GameState
+	// A HumanAgent marks a seat that is played on the terminal
+	agents []Agent
+	names  []string
+	hint   Agent
+	in     *bufio.Scanner
+	out    io.Writer
+	// Number of discarded tiles before each move made in this session, to put them back on undo
+	discarded []int
+}
+
+// What a human wants to do instead of making a move.
+const (
+	TURN_MOVE = iota
+	TURN_UNDO
+	TURN_QUIT
+)
+
+func newInteractiveGame(game *GameState, agents []Agent, names []string, in io.Reader, out io.Writer) *interactiveGame {
+	return &interactiveGame{game: game, agents: agents, names: names, hint: GreedyAgent{}, in: bufio.NewScanner(in), out: out}
+}
+
+func (p *interactiveGame) isHuman(playerIndex int) bool {
+	_, ok := p.agents[playerIndex].(HumanAgent)
+	return ok
+}
+
+func (p *interactiveGame) playerName(playerIndex int) string {
+	cStart, cEnd := playerIndexColor(playerIndex)
+	return fmt.Sprintf("%vPlayer %v%v (%v)", cStart, playerIndex+1, cEnd, p.names[playerIndex])
+}
+
+// Plays until the deck is empty and scores the end of the game. Returns false if a human quit before.
+func (p *interactiveGame) run() bool {
+	for {
+		discarded := len(p.game.discardedTiles)
+		tile, moves, ok := p.game.drawTile()
+		if !ok {
+			break
+		}
+		player := p.game.players[p.game.currentPlayer]
+
+		var move Move
+		if p.isHuman(player.index) {
+			var action int
+			move, action = p.humanMove(tile, moves)
+			switch action {
+			case TURN_QUIT:
+				endGame(p.game, p.agents)
+				return false
+			case TURN_UNDO:
+				p.game.restoreDiscardedTiles(discarded)
+				p.undo()
+				continue
+			}
+		} else {
+			view := newGameView(p.game, player)
+			move = p.agents[player.index].ChooseMove(view, tile, moves)
+			notation, _ := p.game.moveNotation(move)
+			fmt.Fprintf(p.out, "%v plays %v\n", p.playerName(player.index), notation)
+		}
+
+		p.discarded = append(p.discarded, discarded)
+		p.game.makeMove(move)
+	}
+
+	p.game.finalizeGame()
+	endGame(p.game, p.agents)
+	return true
+}
+
+// Takes back all moves up to and including the last move of a human. Nothing is taken back without one.
+func (p *interactiveGame) undo() {
+	// The moves of this session are the last ones of the game. currentPlayer is the player who made the move
+	humanMove := false
+	for _, m := range p.game.lastMoves[len(p.game.lastMoves)-len(p.discarded):] {
+		humanMove = humanMove || p.isHuman(m.currentPlayer)
+	}
+	if !humanMove {
+		fmt.Fprintf(p.out, "There is no move to take back\n")
+		return
+	}
+
+	for {
+		p.game.reverseLastMove()
+		p.game.restoreDiscardedTiles(p.discarded[len(p.discarded)-1])
+		p.discarded = p.discarded[:len(p.discarded)-1]
+		if p.isHuman(p.game.currentPlayer) {
+			fmt.Fprintf(p.out, "Took back the last move of %v\n", p.playerName(p.game.currentPlayer))
+			return
+		}
+	}
+}
+
+// Reads the next line. Returns false if there is no more input.
+func (p *interactiveGame) ask(prompt string) (string, bool) {
+	fmt.Fprintf(p.out, "%v: ", prompt)
+	if !p.in.Scan() {
+		fmt.Fprintln(p.out)
+		return "", false
+	}
+	return strings.TrimSpace(p.in.Text()), true
+}
+
+// Draws the tile in all four rotations next to each other.
+func (p *interactiveGame) writeRotations(tile Tile) {
+	name := p.game.tileSet.tileName(tile.id)
+	board := map[Pos]Tile{}
+	caption := ""
+	for r := 0; r < 4; r++ {
+		board[Pos{2 * r, 0}], _ = p.game.tileSet.rotatedTile(name, r)
+		caption += fmt.Sprintf("%-10v", fmt.Sprintf(" r%v", r))
+	}
+	writeField(p.out, board)
+	fmt.Fprintln(p.out, strings.TrimRight(caption, " "))
+}
+
+// Returns the name of the part of the tile a meeple can be placed on.
+func meeplePlaceName(t Tile) string {
+	switch side := t.meeple.sideIndex; {
+	case t.meeple.playerIndex == -1:
+		return "no meeple"
+	case side == SIDE_CENTER:
+		return "center (cloister)"
+	case side >= SIDE_FIELD:
+		return meepleSideName(side) + " (farmer)"
+	default:
+		return fmt.Sprintf("%v (%v)", meepleSideName(side), strings.ToLower(t.sides[side].String()))
+	}
+}
+
+func (p *interactiveGame) humanMove(tile Tile, moves []Move) (Move, int) {
+	player := p.game.players[p.game.currentPlayer]
+
+	// The positions the tile fits, in the order of the board
+	possible := map[Pos]bool{}
+	for _, m := range moves {
+		possible[m.pos] = true
+	}
+	positions := sortedPositions(possible)
+	labels := map[Pos]string{}
+	for i, pos := range positions {
+		labels[pos] = strconv.Itoa(i)
+	}
+
+	writeLabeledField(p.out, p.game.board, labels, p.game.lastPlacedTiles()...)
+	for _, pl := range p.game.players {
+		fmt.Fprintf(p.out, "%v: %v points, %v meeples\n", p.playerName(pl.index), pl.score, pl.meeples)
+	}
+	fmt.Fprintf(p.out, "\n%v drew %v, %v tiles left:\n", p.playerName(player.index), p.game.tileSet.tileName(tile.id), len(p.game.tiles)-1)
+	p.writeRotations(tile)
+	fmt.Fprintf(p.out, "Commands: a position number, a move like \"D r1 @-1,0 M:up\", hint, undo, quit\n")
+
+	for {
+		answer, ok := p.ask(fmt.Sprintf("Position [0-%v]", len(positions)-1))
+		if !ok {
+			return Move{}, TURN_QUIT
+		}
+		switch answer {
+		case "":
+			continue
+		case "quit":
+			return Move{}, TURN_QUIT
+		case "undo":
+			return Move{}, TURN_UNDO
+		case "hint":
+			view := newGameView(p.game, player)
+			move := p.hint.ChooseMove(view, tile, moves)
+			notation, _ := p.game.moveNotation(move)
+			fmt.Fprintf(p.out, "Hint: %v (position %v)\n", notation, labels[move.pos])
+			continue
+		}
+
+		if strings.Contains(answer, "@") {
+			move, err := p.game.parseMove(answer)
+			if err != nil {
+				fmt.Fprintln(p.out, err)
+				continue
+			}
+			return move, TURN_MOVE
+		}
+
+		i, err := strconv.Atoi(answer)
+		if err != nil || i < 0 || i >= len(positions) {
+			fmt.Fprintf(p.out, "Unknown position %q\n", answer)
+			continue
+		}
+		if move, ok := p.pickAtPosition(positions[i], moves); ok {
+			return move, TURN_MOVE
+		}
+	}
+}
+
+// Asks for the rotation and the meeple of the move at the position. Returns false to pick another position.
+func (p *interactiveGame) pickAtPosition(pos Pos, moves []Move) (Move, bool) {
+	var rotations []int
+	byRotation := map[int][]Move{}
+	for _, m := range moves {
+		if m.pos != pos {
+			continue
+		}
+		r, _ := p.game.tileSet.rotation(m.tile)
+		if len(byRotation[r]) == 0 {
+			rotations = append(rotations, r)
+		}
+		byRotation[r] = append(byRotation[r], m)
+	}
+
+	rotation := rotations[0]
+	if len(rotations) > 1 {
+		var names []string
+		for _, r := range rotations {
+			names = append(names, fmt.Sprintf("r%v", r))
+		}
+		for picked := false; !picked; {
+			answer, ok := p.ask(fmt.Sprintf("Rotation [%v] or back, enter for %v", strings.Join(names, " "), names[0]))
+			if !ok || answer == "back" {
+				return Move{}, false
+			}
+			if answer == "" {
+				answer = names[0]
+			}
+			r, err := strconv.Atoi(strings.TrimPrefix(answer, "r"))
+			if _, possible := byRotation[r]; err == nil && possible {
+				rotation, picked = r, true
+			} else {
+				fmt.Fprintf(p.out, "Unknown rotation %q\n", answer)
+			}
+		}
+	}
+
+	options := byRotation[rotation]
+	if len(options) == 1 {
+		return options[0], true
+	}
+	for i, m := range options {
+		fmt.Fprintf(p.out, "%3d: %v\n", i, meeplePlaceName(m.tile))
+	}
+	for {
+		answer, ok := p.ask(fmt.Sprintf("Meeple [0-%v] or back, enter for 0", len(options)-1))
+		if !ok || answer == "back" {
+			return Move{}, false
+		}
+		if answer == "" {
+			answer = "0"
+		}
+		if i, err := strconv.Atoi(answer); err == nil && i >= 0 && i < len(options) {
+			return options[i], true
+		}
+		fmt.Fprintf(p.out, "Unknown meeple %q\n", answer)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Plays a human against the first move agent with the given input.
+func playScripted(game *GameState, input string) (bool, string) {
+	var out bytes.Buffer
+	agents := []Agent{HumanAgent{}, FirstMoveAgent{}}
+	finished := newInteractiveGame(game, agents, []string{"human", "first"}, strings.NewReader(input), &out).run()
+	return finished, out.String()
+}
+
+func TestInteractiveGame(t *testing.T) {
+	game := generateInitialBoard(2, 3)
+	// Always the first position, rotation and meeple. Empty lines are ignored when asked for the position
+	finished, out := playScripted(&game, strings.Repeat("0\n\n\n", 100))
+
+	if !finished {
+		t.Fatalf("The game wasn't finished:\n%v", out)
+	}
+	if len(game.tiles) != 0 || !game.lastMoves[len(game.lastMoves)-1].finalization {
+		t.Errorf("The game should be over and scored")
+	}
+	if !strings.Contains(out, "Player 2\033[0m (first) plays ") {
+		t.Errorf("The moves of the agent are missing")
+	}
+}
+
+func TestInteractiveGameQuit(t *testing.T) {
+	game := generateInitialBoard(2, 3)
+	if finished, _ := playScripted(&game, "quit\n"); finished {
+		t.Errorf("The game should have stopped")
+	}
+	if len(game.lastMoves) != 0 {
+		t.Errorf("Expected no moves, got %v", len(game.lastMoves))
+	}
+	// No more input stops the game as well
+	if finished, _ := playScripted(&game, "0\n"); finished {
+		t.Errorf("The game should have stopped at the end of the input")
+	}
+}
+
+func TestInteractiveGameUndo(t *testing.T) {
+	game := generateInitialBoard(2, 3)
+	expected := game.copy()
+	expected.drawTile()
+
+	_, out := playScripted(&game, "undo\n0\n\n\nundo\n")
+	if !strings.Contains(out, "There is no move to take back") {
+		t.Errorf("Undo before the first move should fail:\n%v", out)
+	}
+	if !strings.Contains(out, "Took back the last move of") {
+		t.Errorf("Undo should take back the move:\n%v", out)
+	}
+	// The human move and the move of the agent are taken back
+	if diff := game.Diff(expected); len(diff) > 0 {
+		t.Errorf("Undo should restore the start of the game: %v", diff)
+	}
+}
+
+// Without a move of the human, undo must not take back the moves of the agents.
+func TestInteractiveGameUndoAgentsOnly(t *testing.T) {
+	game := generateInitialBoard(2, 3)
+	var out bytes.Buffer
+	newInteractiveGame(&game, []Agent{FirstMoveAgent{}, HumanAgent{}}, []string{"first", "human"}, strings.NewReader("undo\nquit\n"), &out).run()
+
+	if !strings.Contains(out.String(), "There is no move to take back") {
+		t.Errorf("Undo without a human move should fail:\n%v", out.String())
+	}
+	// The agent must not play its move a second time
+	if len(game.lastMoves) != 1 || strings.Count(out.String(), "(first) plays ") != 1 {
+		t.Errorf("The move of the agent should be kept, got %v moves:\n%v", len(game.lastMoves), out.String())
+	}
+}
+
+func TestInteractiveGameNotationAndHint(t *testing.T) {
+	game := generateInitialBoard(2, 3)
+	tile, moves, _ := game.drawTile()
+	hint := game.selectBestMove(moves, game.players[0])
+	notation, _ := game.moveNotation(hint)
+	game.restoreDiscardedTiles(0)
+
+	_, out := playScripted(&game, "hint\nX r0 @5,5\n"+notation+"\n")
+	if !strings.Contains(out, "Hint: "+notation) {
+		t.Errorf("Expected the hint %v:\n%v", notation, out)
+	}
+	if !strings.Contains(out, "the drawn tile is "+game.tileSet.tileName(tile.id)) {
+		t.Errorf("Expected an error for the wrong tile:\n%v", out)
+	}
+	if len(game.lastMoves) != 2 || game.madeMoves()[0] != hint {
+		t.Errorf("Expected the hint to be played, got %v", game.madeMoves())
+	}
+}
+
+func TestWriteLabeledField(t *testing.T) {
+	game := generateInitialBoard(2, 3)
+	var out bytes.Buffer
+	writeLabeledField(&out, game.board, map[Pos]string{Pos{0, -1}: "0", Pos{1, 0}: "12"})
+
+	lines := strings.Split(out.String(), "\n")
+	if !strings.Contains(lines[1], "[  0]") || !strings.HasSuffix(lines[4], "[ 12]") {
+		t.Errorf("The labels are missing:\n%v", out.String())
+	}
+}
+
+// U looks the same at r0 and r2, each meeple place must only be offered once.
+func TestPickAtPositionSymmetricTile(t *testing.T) {
+	tileSet := baseTileSet()
+	u, _ := tileSet.rotatedTile("U", 0)
+	game := generateInitialBoardFromTiles(2, u, []Tile{u})
+	_, moves, _ := game.drawTile()
+
+	var out bytes.Buffer
+	p := newInteractiveGame(&game, []Agent{HumanAgent{}, FirstMoveAgent{}}, []string{"human", "first"}, strings.NewReader(strings.Repeat("\n", 20)), &out)
+	for pos := range game.openPlacements {
+		out.Reset()
+		if _, ok := p.pickAtPosition(pos, moves); !ok {
+			t.Fatalf("Expected a move at %v:\n%v", pos, out.String())
+		}
+		if strings.Count(out.String(), "no meeple") > 1 {
+			t.Errorf("The meeple places at %v are listed more than once:\n%v", pos, out.String())
+		}
+	}
+}
@@ -12,7 +12,7 @@ func init() {
 
 func TestSmallClosedCityPoints(t *testing.T) {
 
-	game := generateInitialBoard(3)
+	game := generateInitialBoard(3, 0)
 	game.board[Pos{0, -1}] = Tile{11, [4]Area{AREA_GRASS, AREA_CITY, AREA_GRASS, AREA_GRASS}, false, false, 0x0, Meeple{1, 2}}
 
 	//drawField(game.board)
@@ -32,7 +32,7 @@ func TestSmallClosedCityPoints(t *testing.T) {
 
 func TestMediumClosedCityPoints(t *testing.T) {
 
-	game := generateInitialBoard(3)
+	game := generateInitialBoard(3, 0)
 
 	conns := connectionsToUint16([]Pos{Pos{0, 1}, Pos{0, 2}, Pos{0, 3}, Pos{1, 2}, Pos{1, 3}, Pos{2, 3}})
 	game.board[Pos{0, -1}] = Tile{10, [4]Area{AREA_CITY, AREA_CITY, AREA_CITY, AREA_CITY}, false, true, conns, Meeple{3, 1}}
@@ -60,7 +60,7 @@ func TestMediumClosedCityPoints(t *testing.T) {
 
 func TestMediumOpenCityPoints(t *testing.T) {
 
-	game := generateInitialBoard(3)
+	game := generateInitialBoard(3, 0)
 
 	conns := connectionsToUint16([]Pos{Pos{0, 1}, Pos{0, 2}, Pos{0, 3}, Pos{1, 2}, Pos{1, 3}, Pos{2, 3}})
 	game.board[Pos{0, -1}] = Tile{10, [4]Area{AREA_CITY, AREA_CITY, AREA_CITY, AREA_CITY}, false, true, conns, Meeple{3, 1}}
@@ -96,7 +96,7 @@ func TestMediumOpenCityPoints(t *testing.T) {
 }
 
 func TestClosedCloisterPoints(t *testing.T) {
-	game := generateInitialBoard(3)
+	game := generateInitialBoard(3, 0)
 
 	game.board[Pos{0, 0}] = Tile{0, [4]Area{AREA_GRASS, AREA_GRASS, AREA_GRASS, AREA_GRASS}, true, false, 0, Meeple{SIDE_CENTER, 2}}
 
@@ -126,7 +126,7 @@ func TestClosedCloisterPoints(t *testing.T) {
 }
 
 func TestOpenCloisterPoints(t *testing.T) {
-	game := generateInitialBoard(3)
+	game := generateInitialBoard(3, 0)
 
 	game.board[Pos{0, 0}] = Tile{0, [4]Area{AREA_GRASS, AREA_GRASS, AREA_GRASS, AREA_GRASS}, true, false, 0, Meeple{SIDE_CENTER, 2}}
 
@@ -155,7 +155,7 @@ func TestOpenCloisterPoints(t *testing.T) {
 }
 
 func TestReverseMove(t *testing.T) {
-	game := generateInitialBoard(3)
+	game := generateInitialBoard(3, 0)
 
 	for j := 0; j < 5; j++ {
 		count := len(game.tiles)
@@ -175,7 +175,7 @@ func TestReverseMove(t *testing.T) {
 			tile := game.tiles[i]
 			i += 1
 
-			moves := generatePossibleMoves(game.board, []Tile{tile}, game.openPlacements, player)
+			moves := generatePossibleMoves(game.board, []Tile{tile}, game.openPlacements, player, len(game.players))
 			if len(moves) > 0 {
 				move := moves[rand.Intn(len(moves))]
 
@@ -203,3 +203,107 @@ func TestReverseMove(t *testing.T) {
 	}
 
 }
+
+func TestFinalizeGame(t *testing.T) {
+	game := generateInitialBoard(3, 0)
+
+	// Open city with an emblem (3 tiles + 1 emblem) for player 1
+	conns := connectionsToUint16([]Pos{Pos{0, 1}})
+	game.board[Pos{0, -1}] = Tile{12, [4]Area{AREA_CITY, AREA_CITY, AREA_GRASS, AREA_GRASS}, false, true, conns, Meeple{SIDE_LEFT, 1}}
+	conns = connectionsToUint16([]Pos{Pos{0, 2}})
+	game.board[Pos{-1, -1}] = Tile{6, [4]Area{AREA_CITY, AREA_GRASS, AREA_CITY, AREA_GRASS}, false, false, conns, Meeple{-1, -1}}
+	// Open road of the start tile (3 tiles) for player 0 and 2
+	conns = connectionsToUint16([]Pos{Pos{0, 2}})
+	game.board[Pos{1, 0}] = Tile{20, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_GRASS}, false, false, conns, Meeple{SIDE_RIGHT, 0}}
+	game.board[Pos{-1, 0}] = Tile{20, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_GRASS}, false, false, conns, Meeple{SIDE_LEFT, 2}}
+	// Open cloister with 3 neighbours for player 2
+	game.board[Pos{0, 1}] = Tile{1, [4]Area{AREA_GRASS, AREA_GRASS, AREA_GRASS, AREA_GRASS}, true, false, 0, Meeple{SIDE_CENTER, 2}}
+
+	//drawField(game.board)
+
+	expectedPoints := []int{3, 4, 7}
+	game.finalizeGame()
+
+	for i, p := range game.players {
+		if p.score != expectedPoints[i] {
+			t.Errorf("Player %v has wrong point count. %v != %v (expected)", i, p.score, expectedPoints[i])
+		}
+	}
+	if meeples := getMeeplePositions(game.board); len(meeples) != 0 {
+		t.Errorf("All meeples should be removed from the board: %v", meeples)
+	}
+	if winners := game.winners(); len(winners) != 1 || winners[0] != 2 {
+		t.Errorf("Player 2 should win but winners are %v", winners)
+	}
+
+	game.reverseLastMove()
+
+	if len(game.board) != 6 {
+		t.Errorf("Reversing the end of the game should not remove any tiles. %v tiles left", len(game.board))
+	}
+	for i, p := range game.players {
+		if p.score != 0 {
+			t.Errorf("Player %v should have a score of 0 but has %v", i, p.score)
+		}
+	}
+	if meeples := getMeeplePositions(game.board); len(meeples) != 4 {
+		t.Errorf("All 4 meeples should be back on the board: %v", meeples)
+	}
+}
+
+func TestReverseMoveKeepsOpenPlacements(t *testing.T) {
+	game := generateInitialBoard(2, 0)
+	for i := 0; i < 2; i++ {
+		moves := generatePossibleMoves(game.board, []Tile{game.tiles[i]}, game.openPlacements, game.players[i], len(game.players))
+		game.makeMove(moves[0])
+	}
+	before := map[Pos]bool{}
+	for p := range game.openPlacements {
+		before[p] = true
+	}
+
+	// The next tile shares open neighbours with the tiles already placed
+	moves := generatePossibleMoves(game.board, []Tile{game.tiles[2]}, game.openPlacements, game.players[0], len(game.players))
+	game.makeMove(moves[0])
+	game.reverseLastMove()
+
+	if len(game.openPlacements) != len(before) {
+		t.Errorf("Expected %v open placements after reversing, got %v", len(before), len(game.openPlacements))
+	}
+	for p := range before {
+		if !game.openPlacements[p] {
+			t.Errorf("%v should still be open after reversing the move", p)
+		}
+	}
+}
+
+// The immediate points of a finished game are exactly what the final scoring awards.
+func TestFinalizeGameMatchesImmediatePoints(t *testing.T) {
+	for seed := int64(0); seed < 5; seed++ {
+		game := generateInitialBoard(3, seed)
+		runGameWithoutScoring(&game)
+
+		expected := make([]int, len(game.players))
+		game.updateImmediatePoints(&expected)
+		for i, p := range game.players {
+			expected[i] += p.score
+		}
+		game.finalizeGame()
+		for i, p := range game.players {
+			if p.score != expected[i] {
+				t.Errorf("Seed %v: player %v has %v points after the final scoring, but %v immediate points", seed, i, p.score, expected[i])
+			}
+		}
+	}
+}
+
+// Plays random moves until the deck is empty.
+func runGameWithoutScoring(game *GameState) {
+	for {
+		_, moves, ok := game.drawTile()
+		if !ok {
+			return
+		}
+		game.makeMove(moves[game.rng.Intn(len(moves))])
+	}
+}
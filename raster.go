@@ -0,0 +1,169 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+)
+
+// Rasterizes the shapes of boardShapes(). Pixels are filled if their center is inside a shape,
+// there is no anti-aliasing. That keeps the output deterministic and small.
+func rasterize(img draw.Image, shapes []shape) {
+	for _, s := range shapes {
+		switch s.kind {
+		case SHAPE_POLYGON:
+			fillPolygon(img, s.points, s.color)
+		case SHAPE_OUTLINE:
+			for i := range s.points {
+				a, b := s.points[i], s.points[(i+1)%len(s.points)]
+				if s.dashed {
+					drawDashedLine(img, a, b, s.size, s.color)
+				} else {
+					drawLine(img, a, b, s.size, s.color)
+				}
+			}
+		case SHAPE_LINE:
+			drawLine(img, s.points[0], s.points[1], s.size, s.color)
+		case SHAPE_CIRCLE:
+			fillCircle(img, s.points[0], s.size, s.color)
+		}
+	}
+}
+
+// Scanline fill with the even-odd rule.
+func fillPolygon(img draw.Image, points []point, c color.Color) {
+	if len(points) < 3 {
+		return
+	}
+	minY, maxY := points[0].y, points[0].y
+	for _, p := range points {
+		minY, maxY = math.Min(minY, p.y), math.Max(maxY, p.y)
+	}
+	bounds := img.Bounds()
+
+	for y := max(int(math.Floor(minY)), bounds.Min.Y); y <= min(int(math.Ceil(maxY)), bounds.Max.Y-1); y++ {
+		scanY := float64(y) + 0.5
+		var xs []float64
+		for i := range points {
+			a, b := points[i], points[(i+1)%len(points)]
+			if (a.y <= scanY) != (b.y <= scanY) {
+				xs = append(xs, a.x+(scanY-a.y)*(b.x-a.x)/(b.y-a.y))
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			// Pixel x is filled if its center x+0.5 is inside
+			for x := max(int(math.Ceil(xs[i]-0.5)), bounds.Min.X); x <= min(int(math.Floor(xs[i+1]-0.5)), bounds.Max.X-1); x++ {
+				img.Set(x, y, c)
+			}
+		}
+	}
+}
+
+// Draws a line with the given width as a polygon.
+func drawLine(img draw.Image, a, b point, width float64, c color.Color) {
+	dx, dy := b.x-a.x, b.y-a.y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return
+	}
+	n := point{-dy / length * width / 2, dx / length * width / 2}
+	fillPolygon(img, []point{a.add(n), b.add(n), b.add(n.scale(-1)), a.add(n.scale(-1))}, c)
+}
+
+// Dashes are three times as long as the line is wide, just like in the SVG output.
+func drawDashedLine(img draw.Image, a, b point, width float64, c color.Color) {
+	length := math.Hypot(b.x-a.x, b.y-a.y)
+	dash := width * 3
+	for start := 0.0; start < length; start += 2 * dash {
+		end := math.Min(start+dash, length)
+		from := point{a.x + (b.x-a.x)*start/length, a.y + (b.y-a.y)*start/length}
+		to := point{a.x + (b.x-a.x)*end/length, a.y + (b.y-a.y)*end/length}
+		drawLine(img, from, to, width, c)
+	}
+}
+
+func fillCircle(img draw.Image, center point, r float64, c color.Color) {
+	bounds := img.Bounds()
+	for y := max(int(center.y-r), bounds.Min.Y); y <= min(int(center.y+r), bounds.Max.Y-1); y++ {
+		for x := max(int(center.x-r), bounds.Min.X); x <= min(int(center.x+r), bounds.Max.X-1); x++ {
+			if math.Hypot(float64(x)+0.5-center.x, float64(y)+0.5-center.y) <= r {
+				img.Set(x, y, c)
+			}
+		}
+	}
+}
+
+// Returns the board as image, with the tile size of the options.
+func renderBoard(board map[Pos]Tile, options RenderOptions) *image.RGBA {
+	shapes, width, height := boardShapes(board, options)
+	img := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(width)), int(math.Ceil(height))))
+	rasterize(img, shapes)
+	return img
+}
+
+func writePNG(w io.Writer, board map[Pos]Tile, options RenderOptions) error {
+	return png.Encode(w, renderBoard(board, options))
+}
+
+// Writes the board of the game as PNG, like writeGameSVG().
+func writeGamePNG(game GameState, options RenderOptions, path string) error {
+	options.openPlacements = game.openPlacements
+	options.highlight = game.lastPlacedTiles()
+
+	return writeFile(path, func(w io.Writer) error {
+		return writePNG(w, game.board, options)
+	})
+}
+
+// All colors used by the renderer. Enough for a GIF, which can only have 256 colors.
+func renderPalette() color.Palette {
+	palette := color.Palette{g_colorBackground, g_colorGrass, g_colorCity, g_colorRoad, g_colorJunction,
+		g_colorCloister, g_colorEmblem, g_colorOutline, g_colorHighlight, g_colorOpen}
+	for _, c := range g_playerColors {
+		palette = append(palette, c)
+	}
+	return palette
+}
+
+// Writes an animated GIF of the game: One frame per move, from the start tile to the current board.
+// The moves are taken from the lastMoves stack, the game itself is not changed. delay is in 100ths of a second.
+func writeGameGIF(w io.Writer, game GameState, options RenderOptions, delay int) error {
+	game = game.copy()
+
+	// All frames need the same size, so they include every position of the final board
+	options.include = nil
+	for pos := range game.board {
+		options.include = append(options.include, pos)
+	}
+
+	var frames []*image.Paletted
+	palette := renderPalette()
+	for {
+		options.highlight = game.lastPlacedTiles()
+		shapes, width, height := boardShapes(game.board, options)
+		frame := image.NewPaletted(image.Rect(0, 0, int(math.Ceil(width)), int(math.Ceil(height))), palette)
+		rasterize(frame, shapes)
+		frames = append(frames, frame)
+
+		// The final scoring only removes meeples. It gets its own frame as well
+		if len(game.lastMoves) == 0 {
+			break
+		}
+		game.reverseLastMove()
+	}
+
+	anim := gif.GIF{LoopCount: 0}
+	for i := len(frames) - 1; i >= 0; i-- {
+		anim.Image = append(anim.Image, frames[i])
+		anim.Delay = append(anim.Delay, delay)
+	}
+	// Stay a bit longer on the final board
+	anim.Delay[len(anim.Delay)-1] = delay * 5
+	return gif.EncodeAll(w, &anim)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"testing"
+)
+
+func TestPNG(t *testing.T) {
+	game := renderTestGame()
+	options := defaultRenderOptions()
+	options.tileSize = 20
+	options.openPlacements = game.openPlacements
+	options.highlight = game.lastPlacedTiles()
+
+	var buf bytes.Buffer
+	if err := writePNG(&buf, game.board, options); err != nil {
+		t.Fatal(err)
+	}
+	if *g_updateGolden {
+		checkGolden(t, "testdata/board.png", buf.Bytes())
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Compares the pixels, the compression of the PNG may change with the Go version
+	f, err := os.Open("testdata/board.png")
+	if err != nil {
+		t.Fatalf("Reading the golden file failed (run the test with -update to create it): %v", err)
+	}
+	defer f.Close()
+	expected, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !samePixels(img, expected) {
+		t.Errorf("The image differs from testdata/board.png. Check it and run the test with -update if the change is intended")
+	}
+	_, width, height := boardShapes(game.board, options)
+	if img.Bounds() != image.Rect(0, 0, int(width), int(height)) {
+		t.Errorf("Expected a %vx%v image, got %v", width, height, img.Bounds())
+	}
+}
+
+func samePixels(a, b image.Image) bool {
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	for y := a.Bounds().Min.Y; y < a.Bounds().Max.Y; y++ {
+		for x := a.Bounds().Min.X; x < a.Bounds().Max.X; x++ {
+			if color.RGBAModel.Convert(a.At(x, y)) != color.RGBAModel.Convert(b.At(x, y)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestPNGTile(t *testing.T) {
+	// A is a cloister with a road at the bottom
+	tile, _ := baseTileSet().rotatedTile("A", 0)
+	options := defaultRenderOptions()
+	options.tileSize = 100
+
+	img := renderBoard(map[Pos]Tile{Pos{0, 0}: tile}, options)
+	if img.Bounds().Dx() != 100 || img.Bounds().Dy() != 100 {
+		t.Fatalf("Expected a 100x100 image, got %v", img.Bounds())
+	}
+
+	pixels := []struct {
+		x, y     int
+		expected color.RGBA
+	}{
+		{5, 5, g_colorGrass},
+		{50, 50, g_colorCloister},
+		{50, 90, g_colorRoad},
+		{90, 50, g_colorGrass},
+	}
+	for _, p := range pixels {
+		if c := img.RGBAAt(p.x, p.y); c != p.expected {
+			t.Errorf("Pixel %v,%v: expected %v, got %v", p.x, p.y, p.expected, c)
+		}
+	}
+}
+
+func TestGameGIF(t *testing.T) {
+	game := renderTestGame()
+	before := game.copy()
+	options := defaultRenderOptions()
+	options.tileSize = 10
+
+	var buf bytes.Buffer
+	if err := writeGameGIF(&buf, game, options, 20); err != nil {
+		t.Fatal(err)
+	}
+	if diff := game.Diff(before); len(diff) > 0 {
+		t.Errorf("Writing the GIF changed the game: %v", diff)
+	}
+
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The start tile and one frame per move
+	if len(anim.Image) != len(game.lastMoves)+1 {
+		t.Fatalf("Expected %v frames, got %v", len(game.lastMoves)+1, len(anim.Image))
+	}
+	for i, frame := range anim.Image {
+		if frame.Bounds() != anim.Image[0].Bounds() {
+			t.Errorf("Frame %v has the size %v instead of %v", i, frame.Bounds(), anim.Image[0].Bounds())
+		}
+	}
+
+	// The last frame is the current board
+	last := anim.Image[len(anim.Image)-1]
+	options.highlight = game.lastPlacedTiles()
+	expected := renderBoard(game.board, options)
+	if !samePixels(last, expected) {
+		t.Errorf("The last frame differs from the board")
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Agents are rated with pairwise Elo: a game of n players counts as a game between every pair of players,
+// won by the one with the higher score. The changes are scaled by 1/(n-1), so a game with more players
+// isn't worth more than a game of two. The history of all rated games is kept in a file with one JSON
+// object per line. The ratings are calculated from it in order, so the file is all there is to keep.
+const (
+	ELO_START = 1500
+	ELO_K     = 32
+	// The difference in rating, at which the better agent is expected to win ten times as often
+	ELO_SCALE = 400
+)
+
+// A game in the rating history. Agents are the rating names of the agents in the order of their seats.
+type ratedGame struct {
+	Time   time.Time `json:"time"`
+	Agents []string  `json:"agents"`
+	Scores []int     `json:"scores"`
+}
+
+type agentRating struct {
+	Agent  string  `json:"agent"`
+	Rating float64 `json:"rating"`
+	// Every seat counts as a game. A win shared by several players counts as a fraction of a win
+	Games      int       `json:"games"`
+	Wins       float64   `json:"wins"`
+	Peak       float64   `json:"peak"`
+	LastPlayed time.Time `json:"lastPlayed"`
+}
+
+// Returns the name an agent is rated under: the label after @, like "v2" of "greedy@v2", or else the whole
+// name with all options. A new version of an agent needs a new label, so it is rated on its own.
+// The same agent on several seats has the same name, its games against itself don't change its rating.
+func agentRatingName(name string) string {
+	if _, label, ok := strings.Cut(name, "@"); ok {
+		return label
+	}
+	return name
+}
+
+// Returns the game of the results of the finished game, in which agents (as given to newAgent()) played the seats.
+func newRatedGame(game GameState, agents []string) ratedGame {
+	rated := ratedGame{Time: time.Now()}
+	for i, p := range game.players {
+		rated.Agents = append(rated.Agents, agentRatingName(agents[i]))
+		rated.Scores = append(rated.Scores, p.score)
+	}
+	return rated
+}
+
+// The tournament games with the rating names of the agents.
+func (config TournamentConfig) ratedGames(results []tournamentGame) (games []ratedGame) {
+	now := time.Now()
+	for _, r := range results {
+		game := ratedGame{Time: now, Scores: r.Scores}
+		for _, seat := range r.Seats {
+			game.Agents = append(game.Agents, agentRatingName(config.agents[seat]))
+		}
+		games = append(games, game)
+	}
+	return
+}
+
+func (g ratedGame) validate() error {
+	if len(g.Agents) < 2 || len(g.Agents) > MAX_PLAYERS || len(g.Agents) != len(g.Scores) {
+		return fmt.Errorf("a rated game needs between 2 and %v agents with a score each, got %v and %v", MAX_PLAYERS, g.Agents, g.Scores)
+	}
+	return nil
+}
+
+// Returns the probability that an agent with rating a beats one with rating b.
+func eloExpected(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/ELO_SCALE))
+}
+
+// Returns the ratings after all games, in the order of the games.
+func rateGames(games []ratedGame) map[string]*agentRating {
+	ratings := map[string]*agentRating{}
+	for _, g := range games {
+		for _, name := range g.Agents {
+			if _, ok := ratings[name]; !ok {
+				ratings[name] = &agentRating{Agent: name, Rating: ELO_START, Peak: ELO_START}
+			}
+		}
+
+		best := g.Scores[0]
+		for _, s := range g.Scores {
+			best = max(best, s)
+		}
+		winners := 0
+		for _, s := range g.Scores {
+			if s == best {
+				winners++
+			}
+		}
+
+		// All changes are based on the ratings before the game
+		changes := make([]float64, len(g.Agents))
+		for i, a := range g.Agents {
+			for j, b := range g.Agents {
+				// An agent that plays against itself can't win or lose anything
+				if a == b {
+					continue
+				}
+				actual := 0.5
+				if g.Scores[i] > g.Scores[j] {
+					actual = 1
+				} else if g.Scores[i] < g.Scores[j] {
+					actual = 0
+				}
+				changes[i] += ELO_K / float64(len(g.Agents)-1) * (actual - eloExpected(ratings[a].Rating, ratings[b].Rating))
+			}
+		}
+
+		for i, name := range g.Agents {
+			r := ratings[name]
+			r.Rating += changes[i]
+			r.Peak = math.Max(r.Peak, r.Rating)
+			r.Games++
+			if g.Scores[i] == best {
+				r.Wins += 1 / float64(winners)
+			}
+			r.LastPlayed = g.Time
+		}
+	}
+	return ratings
+}
+
+// Returns the ratings ordered by rating, best first.
+func leaderboard(ratings map[string]*agentRating) []agentRating {
+	var board []agentRating
+	for _, r := range ratings {
+		board = append(board, *r)
+	}
+	sort.Slice(board, func(i, j int) bool {
+		if board[i].Rating != board[j].Rating {
+			return board[i].Rating > board[j].Rating
+		}
+		return board[i].Agent < board[j].Agent
+	})
+	return board
+}
+
+func writeLeaderboard(out io.Writer, board []agentRating) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Rank\tAgent\tRating\tGames\tWins\tWin rate\tPeak\tLast played\t")
+	for i, r := range board {
+		fmt.Fprintf(w, "%v\t%v\t%.0f\t%v\t%.1f\t%.3f\t%.0f\t%v\t\n",
+			i+1, r.Agent, r.Rating, r.Games, r.Wins, r.Wins/float64(r.Games), r.Peak, r.LastPlayed.Format("2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
+
+// Reads the history of rated games. A missing file is an empty history.
+func loadRatingHistory(path string) ([]ratedGame, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var games []ratedGame
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var g ratedGame
+		if err := json.Unmarshal(scanner.Bytes(), &g); err != nil {
+			return nil, fmt.Errorf("%v:%v: %v", path, line, err)
+		}
+		if err := g.validate(); err != nil {
+			return nil, fmt.Errorf("%v:%v: %v", path, line, err)
+		}
+		games = append(games, g)
+	}
+	return games, scanner.Err()
+}
+
+// Adds the games to the end of the history. Games with less than two players can't be rated and are left out.
+func appendRatingHistory(path string, games []ratedGame) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, g := range games {
+		if g.validate() != nil {
+			continue
+		}
+		data, err := json.Marshal(g)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Adds the games to the history in the file and shows the leaderboard with them.
+func recordRatings(path string, games []ratedGame, out io.Writer) error {
+	if err := appendRatingHistory(path, games); err != nil {
+		return err
+	}
+	return showLeaderboard(path, out)
+}
+
+func showLeaderboard(path string, out io.Writer) error {
+	history, err := loadRatingHistory(path)
+	if err != nil {
+		return err
+	}
+	return writeLeaderboard(out, leaderboard(rateGames(history)))
+}
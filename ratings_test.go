@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEloExpected(t *testing.T) {
+	if e := eloExpected(1500, 1500); e != 0.5 {
+		t.Errorf("Equal ratings should be even, got %v", e)
+	}
+	if e := eloExpected(1900, 1500); math.Abs(e-10.0/11) > 1e-9 {
+		t.Errorf("400 more should win ten times as often, got %v", e)
+	}
+	if sum := eloExpected(1600, 1450) + eloExpected(1450, 1600); math.Abs(sum-1) > 1e-9 {
+		t.Errorf("The expectations should add up to 1, got %v", sum)
+	}
+}
+
+func TestRateGames(t *testing.T) {
+	games := []ratedGame{
+		{Agents: []string{"a", "b"}, Scores: []int{20, 10}},
+		{Agents: []string{"a", "b", "c"}, Scores: []int{5, 5, 3}},
+		{Agents: []string{"c", "c"}, Scores: []int{8, 9}},
+	}
+
+	ratings := rateGames(games[:1])
+	if ratings["a"].Rating != 1516 || ratings["b"].Rating != 1484 {
+		t.Errorf("Expected 1516 and 1484, got %v and %v", ratings["a"].Rating, ratings["b"].Rating)
+	}
+
+	ratings = rateGames(games)
+	sum := 0.0
+	for _, r := range ratings {
+		sum += r.Rating
+	}
+	if math.Abs(sum-3*ELO_START) > 1e-9 {
+		t.Errorf("The ratings should add up to %v, got %v", 3*ELO_START, sum)
+	}
+	// c lost against both in the second game and can't change its rating by playing itself
+	c := ratings["c"]
+	expected := ELO_START + ELO_K/2.0*(0-eloExpected(ELO_START, 1516)+0-eloExpected(ELO_START, 1484))
+	if math.Abs(c.Rating-expected) > 1e-9 || c.Games != 3 || c.Wins != 1 || c.Peak != ELO_START {
+		t.Errorf("Unexpected rating of c: %+v, expected %v", c, expected)
+	}
+	if a, b := ratings["a"], ratings["b"]; a.Wins != 1.5 || b.Wins != 0.5 || a.Games != 2 || a.Peak != a.Rating || b.Peak != ELO_START {
+		t.Errorf("Unexpected ratings %+v %+v", a, b)
+	}
+
+	board := leaderboard(ratings)
+	if board[0].Agent != "a" || board[2].Agent != "c" {
+		t.Errorf("Unexpected leaderboard %+v", board)
+	}
+}
+
+func TestRatingHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratings.jsonl")
+	if history, err := loadRatingHistory(path); err != nil || len(history) != 0 {
+		t.Fatalf("A missing history should be empty, got %v (%v)", history, err)
+	}
+
+	first := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	games := []ratedGame{
+		{first, []string{"greedy", "random"}, []int{50, 10}},
+		// Can't be rated
+		{first, []string{"greedy"}, []int{50}},
+	}
+	if err := appendRatingHistory(path, games); err != nil {
+		t.Fatal(err)
+	}
+	game := generateInitialBoard(3, 1)
+	runGame(&game, []Agent{GreedyAgent{}, RandomAgent{}, FirstMoveAgent{}})
+	if err := appendRatingHistory(path, []ratedGame{newRatedGame(game, []string{"greedy", "random", "first"})}); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := loadRatingHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || !reflect.DeepEqual(history[0], games[0]) || history[1].Scores[0] != game.players[0].score {
+		t.Errorf("Unexpected history %+v", history)
+	}
+
+	var out bytes.Buffer
+	if err := showLeaderboard(path, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[1], "greedy") {
+		t.Errorf("Unexpected leaderboard:\n%v", out.String())
+	}
+
+	os.WriteFile(path, []byte("{\"agents\": [\"a\", \"b\"], \"scores\": [1, 2]}\n\nnot json\n"), 0644)
+	if _, err := loadRatingHistory(path); err == nil || !strings.Contains(err.Error(), ":3:") {
+		t.Errorf("Expected an error in line 3, got %v", err)
+	}
+	os.WriteFile(path, []byte("{\"agents\": [\"a\", \"b\"], \"scores\": [1]}\n"), 0644)
+	if _, err := loadRatingHistory(path); err == nil {
+		t.Errorf("Expected an error for a missing score")
+	}
+}
+
+func TestTournamentRatedGames(t *testing.T) {
+	config := defaultTournamentConfig([]string{"greedy", "first", "greedy", "mcts:iterations=10@mcts-v2"})
+	results := []tournamentGame{{Seats: []int{2, 1}, Scores: []int{30, 0}}, {Seats: []int{0, 2, 3}, Scores: []int{5, 5, 9}}}
+	games := config.ratedGames(results)
+	// Agents listed twice are rated as the same agent, labels replace the name
+	if len(games) != 2 || !reflect.DeepEqual(games[0].Agents, []string{"greedy", "first"}) || !reflect.DeepEqual(games[0].Scores, []int{30, 0}) ||
+		!reflect.DeepEqual(games[1].Agents, []string{"greedy", "greedy", "mcts-v2"}) {
+		t.Errorf("Unexpected rated games %+v", games)
+	}
+	if _, err := newAgent("mcts:iterations=10@"); err == nil {
+		t.Errorf("Expected an error for an empty label")
+	}
+	if agent, err := newAgent("greedy@v2"); err != nil || agent != (GreedyAgent{}) {
+		t.Errorf("The label should be ignored by newAgent(), got %v (%v)", agent, err)
+	}
+	if name := unlabeledAgentName("human@me"); name != "human" {
+		t.Errorf("Expected the agent human without the label, got %v", name)
+	}
+}
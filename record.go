@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A game record, similar to PGN in chess. Headers like [Seed "42"] come first, followed by the
+// numbered moves in notation (see notation.go), one per line:
+//
+//	[TileSet "base"]
+//	[Seed "42"]
+//	[Players "greedy,mcts"]
+//	[Result "34,51"]
+//
+//	1. D r1 @-1,0 M:up
+//	2. V r0 @0,1
+//
+// The moves are made in turn order. The deck follows from the tile set, its expansions and the seed,
+// so unplaceable tiles are discarded during the replay just like in the original game.
+// Lines starting with ; are comments and can be used to annotate the game. A comment belongs to the
+// move after it, comments after the last move are kept at the end.
+type GameRecord struct {
+	headers []recordHeader
+	moves   []string
+	// The comment lines before the move with the index, without the ;
+	comments map[int][]string
+}
+
+type recordHeader struct {
+	key   string
+	value string
+}
+
+func (rec GameRecord) header(key string) (string, bool) {
+	for _, h := range rec.headers {
+		if h.key == key {
+			return h.value, true
+		}
+	}
+	return "", false
+}
+
+func (rec *GameRecord) setHeader(key, value string) {
+	for i, h := range rec.headers {
+		if h.key == key {
+			rec.headers[i].value = value
+			return
+		}
+	}
+	rec.headers = append(rec.headers, recordHeader{key, value})
+}
+
+// Returns all moves that were made in the game, reconstructed from its history.
+func (game GameState) madeMoves() (moves []Move) {
+	for _, m := range game.lastMoves {
+		if !m.finalization {
+			moves = append(moves, game.madeMove(m))
+		}
+	}
+	return
+}
+
+// Returns the move of the history entry. The meeple is the one placed with the tile, even if it was returned since.
+func (game GameState) madeMove(m ReverseMove) Move {
+	tile := game.board[m.removeTileFromBoard]
+	tile.meeple = Meeple{-1, -1}
+	if m.boardToPlayerMeeple.playerIndex != -1 {
+		tile.meeple = Meeple{m.boardToPlayerMeeple.side, m.boardToPlayerMeeple.playerIndex}
+	}
+	return Move{tile, m.removeTileFromBoard}
+}
+
+// Returns the position of the last placed tile, or nothing if no tile was placed yet.
+func (game GameState) lastPlacedTiles() []Pos {
+	for i := len(game.lastMoves) - 1; i >= 0; i-- {
+		if !game.lastMoves[i].finalization {
+			return []Pos{game.lastMoves[i].removeTileFromBoard}
+		}
+	}
+	return nil
+}
+
+// Returns the record of the game. players are the names of the players, expansions the enabled
+// expansions of the tile set. The result is only set if the game was finalized.
+func (game GameState) record(players []string, expansions []string) (GameRecord, error) {
+	var rec GameRecord
+	rec.setHeader("TileSet", game.tileSet.name)
+	if len(expansions) > 0 {
+		rec.setHeader("Expansions", strings.Join(expansions, ","))
+	}
+	rec.setHeader("Seed", strconv.FormatInt(game.seed, 10))
+	rec.setHeader("Players", strings.Join(players, ","))
+
+	if len(game.lastMoves) > 0 && game.lastMoves[len(game.lastMoves)-1].finalization {
+		var scores []string
+		for _, p := range game.players {
+			scores = append(scores, strconv.Itoa(p.score))
+		}
+		rec.setHeader("Result", strings.Join(scores, ","))
+	}
+
+	for _, move := range game.madeMoves() {
+		notation, err := game.moveNotation(move)
+		if err != nil {
+			return rec, err
+		}
+		rec.moves = append(rec.moves, notation)
+	}
+	return rec, nil
+}
+
+func (rec GameRecord) write(w io.Writer) error {
+	for _, h := range rec.headers {
+		if _, err := fmt.Fprintf(w, "[%v %q]\n", h.key, h.value); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(w); err != nil {
+		return err
+	}
+	for i := 0; i <= len(rec.moves); i++ {
+		for _, c := range rec.comments[i] {
+			if _, err := fmt.Fprintf(w, ";%v\n", c); err != nil {
+				return err
+			}
+		}
+		if i == len(rec.moves) {
+			break
+		}
+		if _, err := fmt.Fprintf(w, "%v. %v\n", i+1, rec.moves[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rec *GameRecord) addComment(move int, comment string) {
+	if rec.comments == nil {
+		rec.comments = map[int][]string{}
+	}
+	rec.comments[move] = append(rec.comments[move], comment)
+}
+
+func parseRecord(r io.Reader) (GameRecord, error) {
+	var rec GameRecord
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, ";"):
+			rec.addComment(len(rec.moves), strings.TrimPrefix(line, ";"))
+		case strings.HasPrefix(line, "["):
+			parts := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"), " ", 2)
+			if !strings.HasSuffix(line, "]") || len(parts) != 2 {
+				return rec, fmt.Errorf("line %v: invalid header %q", lineNumber, line)
+			}
+			value, err := strconv.Unquote(parts[1])
+			if err != nil {
+				return rec, fmt.Errorf("line %v: invalid header value %v", lineNumber, parts[1])
+			}
+			rec.setHeader(parts[0], value)
+		default:
+			parts := strings.SplitN(line, ". ", 2)
+			if len(parts) != 2 || parts[0] != strconv.Itoa(len(rec.moves)+1) {
+				return rec, fmt.Errorf("line %v: expected move %v, got %q", lineNumber, len(rec.moves)+1, line)
+			}
+			rec.moves = append(rec.moves, strings.TrimSpace(parts[1]))
+		}
+	}
+	return rec, scanner.Err()
+}
+
+// Returns the game before the first move. tileSets returns the tile set of the given name, the "base"
+// tile set is always known. tileSets may be nil, if only the base tile set is known.
+func (rec GameRecord) startGame(tileSets func(name string) (TileSet, error)) (GameState, error) {
+	tileSet := baseTileSet()
+	if name, _ := rec.header("TileSet"); name != "" && name != tileSet.name {
+		if tileSets == nil {
+			return GameState{}, fmt.Errorf("unknown tile set %q", name)
+		}
+		var err error
+		if tileSet, err = tileSets(name); err != nil {
+			return GameState{}, err
+		}
+	}
+
+	var expansions []string
+	if e, ok := rec.header("Expansions"); ok && e != "" {
+		expansions = strings.Split(e, ",")
+	}
+	s, _ := rec.header("Seed")
+	seed, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return GameState{}, fmt.Errorf("invalid seed %q", s)
+	}
+	players, _ := rec.header("Players")
+	playerCount := len(strings.Split(players, ","))
+	if players == "" || playerCount > MAX_PLAYERS {
+		return GameState{}, fmt.Errorf("invalid players %q", players)
+	}
+
+	return generateTileSetBoard(playerCount, tileSet, expansions, seed), nil
+}
+
+// Plays the recorded game again, see startGame(). The game is finalized if the record has a result.
+func (rec GameRecord) replay(tileSets func(name string) (TileSet, error)) (GameState, error) {
+	game, err := rec.startGame(tileSets)
+	if err != nil {
+		return game, err
+	}
+
+	for i, notation := range rec.moves {
+		if _, _, ok := game.drawTile(); !ok {
+			return game, fmt.Errorf("move %v: the deck is empty", i+1)
+		}
+		move, err := game.parseMove(notation)
+		if err != nil {
+			return game, fmt.Errorf("move %v: %v", i+1, err)
+		}
+		game.makeMove(move)
+	}
+
+	if result, ok := rec.header("Result"); ok {
+		if _, _, ok := game.drawTile(); ok {
+			return game, fmt.Errorf("the game has a result, but there are still tiles left")
+		}
+		game.finalizeGame()
+		var scores []string
+		for _, p := range game.players {
+			scores = append(scores, strconv.Itoa(p.score))
+		}
+		if strings.Join(scores, ",") != result {
+			return game, fmt.Errorf("the replay ends with %v instead of the result %v", strings.Join(scores, ","), result)
+		}
+	}
+	return game, nil
+}
+
+func writeRecordFile(game GameState, players []string, expansions []string, path string) error {
+	rec, err := game.record(players, expansions)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, rec.write)
+}
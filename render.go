@@ -0,0 +1,267 @@
+package main
+
+import (
+	"image/color"
+)
+
+// The board is rendered as a list of simple shapes, which are then written as SVG or rasterized.
+// Both renderers draw the shapes in order, so later shapes cover earlier ones.
+const (
+	SHAPE_POLYGON = iota
+	// Closed outline of the points with the stroke width
+	SHAPE_OUTLINE
+	// Line from the first to the second point with the stroke width
+	SHAPE_LINE
+	SHAPE_CIRCLE
+)
+
+type point struct {
+	x, y float64
+}
+
+type shape struct {
+	kind   int
+	points []point
+	// Circle radius or stroke width
+	size   float64
+	color  color.RGBA
+	dashed bool
+}
+
+type RenderOptions struct {
+	// Width and height of a tile in pixels
+	tileSize float64
+	// Tiles to mark, usually the last placed one
+	highlight []Pos
+	// Draws the open placements if set
+	openPlacements map[Pos]bool
+	// Positions that are part of the image even if they are empty
+	include []Pos
+}
+
+var (
+	g_colorBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	g_colorGrass      = color.RGBA{0x7c, 0xb3, 0x42, 0xff}
+	g_colorCity       = color.RGBA{0xc8, 0x9f, 0x62, 0xff}
+	g_colorRoad       = color.RGBA{0xf2, 0xea, 0xd3, 0xff}
+	g_colorJunction   = color.RGBA{0x5d, 0x40, 0x37, 0xff}
+	g_colorCloister   = color.RGBA{0xb5, 0x53, 0x3c, 0xff}
+	g_colorEmblem     = color.RGBA{0x1e, 0x5a, 0xc8, 0xff}
+	g_colorOutline    = color.RGBA{0x00, 0x00, 0x00, 0xff}
+	g_colorHighlight  = color.RGBA{0xff, 0x8f, 0x00, 0xff}
+	g_colorOpen       = color.RGBA{0x9e, 0x9e, 0x9e, 0xff}
+	// The same order as the terminal colors of playerIndexColor()
+	g_playerColors = []color.RGBA{
+		{0xe5, 0x39, 0x35, 0xff},
+		{0x43, 0xa0, 0x47, 0xff},
+		{0xfd, 0xd8, 0x35, 0xff},
+		{0x1e, 0x88, 0xe5, 0xff},
+		{0x8e, 0x24, 0xaa, 0xff},
+		{0x00, 0xac, 0xc1, 0xff},
+	}
+)
+
+func defaultRenderOptions() RenderOptions {
+	return RenderOptions{tileSize: 60}
+}
+
+func (p point) add(o point) point {
+	return point{p.x + o.x, p.y + o.y}
+}
+
+func (p point) scale(f float64) point {
+	return point{p.x * f, p.y * f}
+}
+
+// The corners of a tile of size 1, so that side s goes from corner s to corner s+1.
+var g_tileCorners = []point{{0, 0}, {0, 1}, {1, 1}, {1, 0}}
+
+// Returns the point on side s at t (0..1 in perimeter order), moved inwards by depth.
+func sidePoint(side int, t, depth float64) point {
+	a, b := g_tileCorners[side], g_tileCorners[(side+1)%4]
+	p := point{a.x + (b.x-a.x)*t, a.y + (b.y-a.y)*t}
+	center := point{0.5, 0.5}
+	return point{p.x + (center.x-p.x)*2*depth, p.y + (center.y-p.y)*2*depth}
+}
+
+// Returns where a meeple on the given side is drawn.
+func meeplePoint(side int) point {
+	switch {
+	case side == SIDE_CENTER:
+		return point{0.5, 0.5}
+	case side >= SIDE_FIELD:
+		segment := side - SIDE_FIELD
+		return sidePoint(fieldSegmentSide(segment), 0.25+0.5*float64(segment%2), 0.12)
+	}
+	return sidePoint(side, 0.5, 0.22)
+}
+
+// Returns the shapes of a tile of size 1 at the origin.
+func tileShapes(t Tile) (shapes []shape) {
+	shapes = append(shapes, shape{SHAPE_POLYGON, g_tileCorners, 0, g_colorGrass, false})
+
+	center := point{0.5, 0.5}
+	for side := 0; side < 4; side++ {
+		if t.sides[side] != AREA_CITY {
+			continue
+		}
+		a, b := g_tileCorners[side], g_tileCorners[(side+1)%4]
+		if t.hasConnectionAtSide(side) {
+			shapes = append(shapes, shape{SHAPE_POLYGON, []point{a, b, center}, 0, g_colorCity, false})
+		} else {
+			shapes = append(shapes, shape{SHAPE_POLYGON, []point{a, b, sidePoint(side, 0.5, 0.3)}, 0, g_colorCity, false})
+		}
+	}
+	for side := 0; side < 4; side++ {
+		// Connected cities are joined in the center
+		if t.sides[side] == AREA_CITY && t.hasConnectionAtSide(side) {
+			square := []point{{0.25, 0.25}, {0.25, 0.75}, {0.75, 0.75}, {0.75, 0.25}}
+			shapes = append(shapes, shape{SHAPE_POLYGON, square, 0, g_colorCity, false})
+			break
+		}
+	}
+
+	// Connected road sides are drawn as one road, so the two curves of TierI don't cross.
+	// Other roads end in the center.
+	ends := 0
+	var drawn [4]bool
+	for side := 0; side < 4; side++ {
+		if t.sides[side] != AREA_ROAD || drawn[side] {
+			continue
+		}
+		var group []int
+		for s := side; s < 4; s++ {
+			if t.sides[s] == AREA_ROAD && t.isConnected(side, s) {
+				group = append(group, s)
+				drawn[s] = true
+			}
+		}
+		if len(group) == 2 {
+			shapes = append(shapes, roadShapes(group[0], group[1])...)
+			continue
+		}
+		if len(group) == 1 {
+			ends++
+		}
+		for _, s := range group {
+			shapes = append(shapes, shape{SHAPE_LINE, []point{sidePoint(s, 0.5, 0), center}, 0.1, g_colorRoad, false})
+		}
+	}
+	// Roads end at junctions (and cloisters, which are drawn on top)
+	if ends > 2 || (ends > 0 && !t.cloister) {
+		square := []point{{0.42, 0.42}, {0.42, 0.58}, {0.58, 0.58}, {0.58, 0.42}}
+		shapes = append(shapes, shape{SHAPE_POLYGON, square, 0, g_colorJunction, false})
+	}
+
+	if t.cloister {
+		square := []point{{0.33, 0.33}, {0.33, 0.67}, {0.67, 0.67}, {0.67, 0.33}}
+		shapes = append(shapes, shape{SHAPE_POLYGON, square, 0, g_colorCloister, false})
+	}
+
+	if t.emblem {
+		for side := 0; side < 4; side++ {
+			if t.sides[side] == AREA_CITY {
+				shapes = append(shapes, shape{SHAPE_CIRCLE, []point{sidePoint(side, 0.25, 0.12)}, 0.07, g_colorEmblem, false})
+				break
+			}
+		}
+	}
+
+	if t.meeple.playerIndex != -1 {
+		p := meeplePoint(t.meeple.sideIndex)
+		c := g_playerColors[t.meeple.playerIndex%len(g_playerColors)]
+		if t.meeple.isFarmer() {
+			diamond := func(r float64) []point {
+				return []point{{p.x - r, p.y}, {p.x, p.y + r}, {p.x + r, p.y}, {p.x, p.y - r}}
+			}
+			shapes = append(shapes, shape{SHAPE_POLYGON, diamond(0.13), 0, g_colorOutline, false})
+			shapes = append(shapes, shape{SHAPE_POLYGON, diamond(0.1), 0, c, false})
+		} else {
+			shapes = append(shapes, shape{SHAPE_CIRCLE, []point{p}, 0.11, g_colorOutline, false})
+			shapes = append(shapes, shape{SHAPE_CIRCLE, []point{p}, 0.085, c, false})
+		}
+	}
+	return
+}
+
+// Returns the shapes of a road from side a to side b. A straight road goes through the center,
+// a curve bends towards the corner between both sides.
+func roadShapes(a, b int) []shape {
+	from, to := sidePoint(a, 0.5, 0), sidePoint(b, 0.5, 0)
+	if (a+2)%4 == b {
+		return []shape{{SHAPE_LINE, []point{from, to}, 0.1, g_colorRoad, false}}
+	}
+
+	corner := g_tileCorners[b]
+	if (b+1)%4 == a {
+		corner = g_tileCorners[a]
+	}
+	center := point{0.5, 0.5}
+	bend := center.add(corner.add(center.scale(-1)).scale(0.4))
+	return []shape{
+		{SHAPE_LINE, []point{from, bend}, 0.1, g_colorRoad, false},
+		{SHAPE_LINE, []point{bend, to}, 0.1, g_colorRoad, false},
+		// Covers the gap between both lines
+		{SHAPE_CIRCLE, []point{bend}, 0.05, g_colorRoad, false},
+	}
+}
+
+// Moves and scales the shapes of a tile of size 1.
+func transformShapes(shapes []shape, offset point, size float64) []shape {
+	for i, s := range shapes {
+		points := make([]point, len(s.points))
+		for j, p := range s.points {
+			points[j] = p.scale(size).add(offset)
+		}
+		shapes[i].points = points
+		shapes[i].size = s.size * size
+	}
+	return shapes
+}
+
+// Returns the shapes of the whole board and its size in pixels. The tiles are drawn in sorted order, so
+// the output only depends on the board and the options.
+func boardShapes(board map[Pos]Tile, options RenderOptions) (shapes []shape, width, height float64) {
+	positions := map[Pos]bool{}
+	for pos := range board {
+		positions[pos] = true
+	}
+	for pos := range options.openPlacements {
+		positions[pos] = true
+	}
+	for _, pos := range options.include {
+		positions[pos] = true
+	}
+
+	minX, maxX, minY, maxY := 0, 0, 0, 0
+	for pos := range positions {
+		minX, maxX = min(minX, pos.x), max(maxX, pos.x)
+		minY, maxY = min(minY, pos.y), max(maxY, pos.y)
+	}
+	size := options.tileSize
+	width, height = float64(maxX-minX+1)*size, float64(maxY-minY+1)*size
+	offset := func(pos Pos) point {
+		return point{float64(pos.x-minX) * size, float64(pos.y-minY) * size}
+	}
+	square := func(pos Pos, inset float64) []point {
+		o := offset(pos)
+		return []point{{o.x + inset, o.y + inset}, {o.x + inset, o.y + size - inset}, {o.x + size - inset, o.y + size - inset}, {o.x + size - inset, o.y + inset}}
+	}
+
+	shapes = append(shapes, shape{SHAPE_POLYGON, []point{{0, 0}, {0, height}, {width, height}, {width, 0}}, 0, g_colorBackground, false})
+
+	for _, pos := range sortedPositions(positions) {
+		if t, ok := board[pos]; ok {
+			shapes = append(shapes, transformShapes(tileShapes(t), offset(pos), size)...)
+		}
+	}
+	for _, pos := range sortedPositions(options.openPlacements) {
+		shapes = append(shapes, shape{SHAPE_OUTLINE, square(pos, size*0.1), size * 0.03, g_colorOpen, true})
+	}
+	for _, pos := range options.highlight {
+		if _, ok := board[pos]; ok {
+			shapes = append(shapes, shape{SHAPE_OUTLINE, square(pos, size*0.03), size * 0.06, g_colorHighlight, false})
+		}
+	}
+	return
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+var g_updateGolden = flag.Bool("update", false, "Writes the golden files in testdata instead of comparing against them")
+
+// A board with all kinds of meeples. It only depends on the seed.
+func renderTestGame() GameState {
+	game := generateInitialBoard(3, 2)
+	for i := 0; i < 30; i++ {
+		_, moves, ok := game.drawTile()
+		if !ok {
+			break
+		}
+		game.makeMove(moves[(i*7)%len(moves)])
+	}
+	return game
+}
+
+func checkGolden(t *testing.T, path string, data []byte) {
+	if *g_updateGolden {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Reading the golden file failed (run the test with -update to create it): %v", err)
+	}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("The output differs from %v. Check it and run the test with -update if the change is intended", path)
+	}
+}
+
+func TestSVGGolden(t *testing.T) {
+	game := renderTestGame()
+	options := defaultRenderOptions()
+	options.openPlacements = game.openPlacements
+	options.highlight = game.lastPlacedTiles()
+
+	var buf bytes.Buffer
+	if err := writeSVG(&buf, game.board, options); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "testdata/board.svg", buf.Bytes())
+
+	// Every meeple is drawn in the color of its player
+	for _, pos := range sortedPositions(getMeeplePositions(game.board)) {
+		c := svgColor(g_playerColors[game.board[pos].meeple.playerIndex])
+		if !strings.Contains(buf.String(), c) {
+			t.Errorf("No meeple with color %v", c)
+		}
+	}
+}
+
+// Every tile type of the base tile set including the extra tiles, 8 per row. TierI has two separate
+// curves, which must not look like a crossing.
+func TestSVGTileTypesGolden(t *testing.T) {
+	ts := baseTileSet()
+	board := map[Pos]Tile{}
+	for id := range ts.types {
+		tile, _ := ts.rotatedTile(ts.tileName(id), 0)
+		board[Pos{id % 8, id / 8}] = tile
+	}
+
+	var buf bytes.Buffer
+	if err := writeSVG(&buf, board, defaultRenderOptions()); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "testdata/tiles.svg", buf.Bytes())
+
+	id, _ := ts.tileId("TierI")
+	shapes := tileShapes(ts.types[id])
+	for _, s := range shapes {
+		if s.kind == SHAPE_LINE && (s.points[0] == point{0.5, 0.5} || s.points[1] == point{0.5, 0.5}) {
+			t.Errorf("No road of TierI goes through the center: %v", s.points)
+		}
+		if s.color == g_colorJunction {
+			t.Errorf("TierI has no junction")
+		}
+	}
+}
+
+func TestSVGTile(t *testing.T) {
+	// C is the city with all sides connected and an emblem
+	tile, _ := baseTileSet().rotatedTile("C", 0)
+	tile.meeple = Meeple{SIDE_UP, 1}
+
+	var buf bytes.Buffer
+	writeSVG(&buf, map[Pos]Tile{Pos{0, 0}: tile}, defaultRenderOptions())
+	svg := buf.String()
+
+	for _, expected := range []string{`width="60" height="60"`, svgColor(g_colorCity), svgColor(g_colorEmblem), svgColor(g_playerColors[1])} {
+		if !strings.Contains(svg, expected) {
+			t.Errorf("The SVG is missing %q:\n%v", expected, svg)
+		}
+	}
+	if strings.Contains(svg, svgColor(g_colorRoad)) {
+		t.Errorf("The tile has no roads:\n%v", svg)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Steps through a recorded game. Moving forward draws the tiles and makes the recorded moves,
+// moving back uses reverseLastMove(). Tiles that were discarded while drawing go back onto the deck,
+// so the game is always in the same state as the original game after the same number of moves.
+type replayViewer struct {
+	rec     GameRecord
+	game    GameState
+	players []string
+	// Number of discarded tiles before each move
+	discarded []int
+	// The final scoring is the last step, if the record has a result
+	finalize bool
+}
+
+func newReplayViewer(rec GameRecord, tileSets func(name string) (TileSet, error)) (*replayViewer, error) {
+	// Validates the whole record first, so stepping through it can't fail
+	if _, err := rec.replay(tileSets); err != nil {
+		return nil, err
+	}
+	game, _ := rec.startGame(tileSets)
+	players, _ := rec.header("Players")
+	_, finalize := rec.header("Result")
+
+	return &replayViewer{rec: rec, game: game, players: strings.Split(players, ","), finalize: finalize}, nil
+}
+
+func (v *replayViewer) steps() int {
+	if v.finalize {
+		return len(v.rec.moves) + 1
+	}
+	return len(v.rec.moves)
+}
+
+// Number of moves made so far, including the final scoring.
+func (v *replayViewer) position() int {
+	return len(v.game.lastMoves)
+}
+
+func (v *replayViewer) forward() bool {
+	position := v.position()
+	if position >= v.steps() {
+		return false
+	}
+	if position == len(v.rec.moves) {
+		v.game.finalizeGame()
+		return true
+	}
+
+	v.discarded = append(v.discarded, len(v.game.discardedTiles))
+	v.game.drawTile()
+	// The record was validated when the viewer was created
+	move, _ := v.game.parseMove(v.rec.moves[position])
+	v.game.makeMove(move)
+	return true
+}
+
+func (v *replayViewer) back() bool {
+	position := v.position()
+	if position == 0 {
+		return false
+	}
+	v.game.reverseLastMove()
+	if position > len(v.rec.moves) {
+		return true
+	}
+
+	v.game.restoreDiscardedTiles(v.discarded[position-1])
+	v.discarded = v.discarded[:position-1]
+	return true
+}
+
+func (v *replayViewer) goTo(position int) {
+	for v.position() < position && v.forward() {
+	}
+	for v.position() > position && v.back() {
+	}
+}
+
+// Returns the deck composition, like "A:2 B:4 D:3".
+func (v *replayViewer) deckComposition() string {
+	counts := countTileIds(v.game.tiles)
+	var parts []string
+	for id := range v.game.tileSet.types {
+		if counts[id] > 0 {
+			parts = append(parts, fmt.Sprintf("%v:%v", v.game.tileSet.tileName(id), counts[id]))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (v *replayViewer) draw(w io.Writer) {
+	position := v.position()
+
+	writeField(w, v.game.board, v.game.lastPlacedTiles()...)
+
+	switch {
+	case position == 0:
+		fmt.Fprintf(w, "Start of the game, %v moves\n", len(v.rec.moves))
+	case position > len(v.rec.moves):
+		fmt.Fprintf(w, "Final scoring\n")
+	default:
+		player := (position - 1) % len(v.players)
+		fmt.Fprintf(w, "Move %v/%v: %v by %v\n", position, len(v.rec.moves), v.rec.moves[position-1], v.players[player])
+	}
+	for i, p := range v.game.players {
+		fmt.Fprintf(w, "%v %v\n", p, v.players[i])
+	}
+	fmt.Fprintf(w, "Deck (%v): %v\n", len(v.game.tiles), v.deckComposition())
+	if len(v.game.discardedTiles) > 0 {
+		fmt.Fprintf(w, "Discarded: %v\n", len(v.game.discardedTiles))
+	}
+}
+
+// Reads commands line by line: n (or just enter) for the next move, p for the previous one,
+// s and e for the start and the end, g <move> to go to a move and q to quit.
+func (v *replayViewer) run(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	v.draw(out)
+	for {
+		fmt.Fprintf(out, "[n]ext, [p]revious, [s]tart, [e]nd, [g]o <move>, [q]uit: ")
+		if !scanner.Scan() {
+			return
+		}
+		fields := strings.Fields(scanner.Text())
+		command := "n"
+		if len(fields) > 0 {
+			command = fields[0]
+		}
+
+		switch command {
+		case "n":
+			v.forward()
+		case "p":
+			v.back()
+		case "s":
+			v.goTo(0)
+		case "e":
+			v.goTo(v.steps())
+		case "g":
+			position, err := strconv.Atoi(strings.Join(fields[1:], ""))
+			if err != nil {
+				fmt.Fprintf(out, "Invalid move number\n")
+				continue
+			}
+			v.goTo(position)
+		case "q":
+			return
+		default:
+			fmt.Fprintf(out, "Unknown command %q\n", command)
+			continue
+		}
+		v.draw(out)
+	}
+}
+
+func replayFile(path string, tileSets func(name string) (TileSet, error), in io.Reader, out io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rec, err := parseRecord(f)
+	if err != nil {
+		return err
+	}
+	v, err := newReplayViewer(rec, tileSets)
+	if err != nil {
+		return err
+	}
+	v.run(in, out)
+	return nil
+}
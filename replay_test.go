@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func recordedGame(t *testing.T, seed int64) (GameState, GameRecord) {
+	game := generateInitialBoard(2, seed)
+	runGame(&game, []Agent{RandomAgent{}, GreedyAgent{}})
+	rec, err := game.record([]string{"random", "greedy"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return game, rec
+}
+
+func TestReplayViewer(t *testing.T) {
+	// A tile is discarded in this game
+	game, rec := recordedGame(t, 107)
+	v, err := newReplayViewer(rec, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, _ := rec.startGame(nil)
+
+	v.goTo(v.steps())
+	if len(game.discardedTiles) == 0 {
+		t.Errorf("Expected a discarded tile")
+	}
+	if diffs := game.Diff(v.game); len(diffs) > 0 {
+		t.Errorf("The end of the replay differs from the game: %v", diffs)
+	}
+
+	// Going back and forth must give the same states
+	for _, position := range []int{10, 3, 40, 0, v.steps(), v.steps() - 3, 20} {
+		v.goTo(position)
+		expected, _ := rec.startGame(nil)
+		for i := 0; i < position && i < len(rec.moves); i++ {
+			expected.drawTile()
+			move, _ := expected.parseMove(rec.moves[i])
+			expected.makeMove(move)
+		}
+		if position > len(rec.moves) {
+			expected.finalizeGame()
+		}
+		if diffs := expected.Diff(v.game); len(diffs) > 0 {
+			t.Errorf("Wrong state at position %v: %v", position, diffs)
+		}
+	}
+
+	v.goTo(0)
+	if diffs := start.Diff(v.game); len(diffs) > 0 || len(v.game.discardedTiles) > 0 {
+		t.Errorf("The start of the replay differs: %v", diffs)
+	}
+}
+
+func TestReplayViewerCommands(t *testing.T) {
+	_, rec := recordedGame(t, 8)
+	v, err := newReplayViewer(rec, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	v.run(strings.NewReader("n\n\np\ng 5\nx\ne\nq\nn\n"), &out)
+
+	if v.position() != v.steps() {
+		t.Errorf("Expected to be at the end, but at %v", v.position())
+	}
+	for _, expected := range []string{"Start of the game", "Move 2/", "Move 5/", "Unknown command", "Final scoring", "Deck (", "by greedy"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("The output is missing %q", expected)
+		}
+	}
+	if !strings.Contains(out.String(), "*") {
+		t.Errorf("The last placed tile is not highlighted")
+	}
+}
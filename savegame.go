@@ -0,0 +1,415 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Version of the save file format. Increase it with every incompatible change!
+const SAVE_VERSION = 1
+
+// Loading skips the random numbers drawn before, one at a time. That is far more than the search agents draw in a
+// game and still takes only a few seconds to skip.
+const MAX_RANDOM_DRAWS = 1 << 30
+
+// A saved game is self-contained: It includes the tile set, so tiles are referenced by the name of their
+// tile type and the number of rotations by rotateTile(). Every rotation turns the tile a quarter counterclockwise
+// on screen (the left side becomes the lower one, as up is y-1). Meeple sides use the sideIndex of Meeple:
+// 0-3 for the sides, 4 for the center (cloister) and 5-12 for farmers on the field segments.
+type saveFile struct {
+	Version int   `json:"version"`
+	Seed    int64 `json:"seed"`
+	// Random numbers drawn since the seed. The loaded game continues with the next one
+	RandomDraws    uint64        `json:"randomDraws"`
+	TileSet        tileSetFile   `json:"tileSet"`
+	Board          []savedTile   `json:"board"`
+	Deck           []string      `json:"deck"`
+	Discarded      []string      `json:"discarded,omitempty"`
+	Players        []savedPlayer `json:"players"`
+	OpenPlacements []savedPos    `json:"openPlacements"`
+	CurrentPlayer  int           `json:"currentPlayer"`
+	DrawnTile      int           `json:"drawnTile"`
+	// Only saved on request. Without them, the loaded game can't be reversed to its start
+	LastMoves []savedMove `json:"lastMoves,omitempty"`
+}
+
+type savedPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type savedMeeple struct {
+	Player int `json:"player"`
+	Side   int `json:"side"`
+}
+
+type savedTile struct {
+	savedPos
+	Tile     string       `json:"tile"`
+	Rotation int          `json:"rotation"`
+	Meeple   *savedMeeple `json:"meeple,omitempty"`
+}
+
+type savedPlayer struct {
+	Score   int `json:"score"`
+	Meeples int `json:"meeples"`
+}
+
+type savedMeeplePlacement struct {
+	savedPos
+	savedMeeple
+}
+
+type savedPoints struct {
+	Player int `json:"player"`
+	Points int `json:"points"`
+}
+
+type savedMove struct {
+	ToBoard           []savedMeeplePlacement `json:"toBoard,omitempty"`
+	ToPlayer          *savedMeeplePlacement  `json:"toPlayer,omitempty"`
+	Tile              savedPos               `json:"tile"`
+	DeckIndex         int                    `json:"deckIndex"`
+	DeckTile          string                 `json:"deckTile,omitempty"`
+	NewOpenPlacements []savedPos             `json:"newOpenPlacements,omitempty"`
+	Points            []savedPoints          `json:"points,omitempty"`
+	Finalization      bool                   `json:"finalization,omitempty"`
+	CurrentPlayer     int                    `json:"currentPlayer"`
+	DrawnTile         int                    `json:"drawnTile"`
+}
+
+func toSavedPos(p Pos) savedPos {
+	return savedPos{p.x, p.y}
+}
+
+func (p savedPos) pos() Pos {
+	return Pos{p.X, p.Y}
+}
+
+func toSavedPositions(positions []Pos) (saved []savedPos) {
+	for _, p := range positions {
+		saved = append(saved, toSavedPos(p))
+	}
+	return
+}
+
+func toSavedPlacement(r ReverseMeeplePlacement) savedMeeplePlacement {
+	return savedMeeplePlacement{toSavedPos(r.pos), savedMeeple{r.playerIndex, r.side}}
+}
+
+// Returns the number of rotations of the tile type, that result in the given tile.
+func (ts TileSet) rotation(tile Tile) (int, error) {
+	if tile.id < 0 || tile.id >= len(ts.types) {
+		return 0, fmt.Errorf("tile %v is not part of the tile set %q", tile.id, ts.name)
+	}
+	t := ts.types[tile.id]
+	for r := 0; r < 4; r++ {
+		if t.sides == tile.sides && t.connections == tile.connections && t.cloister == tile.cloister && t.emblem == tile.emblem {
+			return r, nil
+		}
+		t = rotateTile(t)
+	}
+	return 0, fmt.Errorf("tile %v is no rotation of the tile type %v", tile, ts.tileName(tile.id))
+}
+
+// Returns the tile type with the given name, rotated and without a meeple.
+func (ts TileSet) rotatedTile(name string, rotation int) (Tile, error) {
+	id, ok := ts.tileId(name)
+	if !ok {
+		return Tile{}, fmt.Errorf("unknown tile %q", name)
+	}
+	if rotation < 0 || rotation > 3 {
+		return Tile{}, fmt.Errorf("tile %v: invalid rotation %v", name, rotation)
+	}
+	tile := ts.types[id]
+	for r := 0; r < rotation; r++ {
+		tile = rotateTile(tile)
+	}
+	tile.meeple = Meeple{-1, -1}
+	return tile, nil
+}
+
+// Returns the name of a tile of the deck. Those are never rotated.
+func (ts TileSet) deckTileName(tile Tile) (string, error) {
+	if r, err := ts.rotation(tile); err != nil || r != 0 || tile.meeple.playerIndex != -1 {
+		return "", fmt.Errorf("tile %v can not be part of the deck", tile)
+	}
+	return ts.tileName(tile.id), nil
+}
+
+func (ts TileSet) deckTileNames(tiles []Tile) ([]string, error) {
+	names := []string{}
+	for _, t := range tiles {
+		name, err := ts.deckTileName(t)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (ts TileSet) deckTiles(names []string) ([]Tile, error) {
+	var tiles []Tile
+	for _, name := range names {
+		tile, err := ts.rotatedTile(name, 0)
+		if err != nil {
+			return nil, err
+		}
+		tiles = append(tiles, tile)
+	}
+	return tiles, nil
+}
+
+func (game GameState) saveFile(history bool) (saveFile, error) {
+	ts := game.tileSet
+	f := saveFile{Version: SAVE_VERSION, Seed: game.seed, RandomDraws: game.rngSource.count, TileSet: ts.file(), CurrentPlayer: game.currentPlayer, DrawnTile: game.drawnTile}
+
+	var err error
+	if f.Deck, err = ts.deckTileNames(game.tiles); err != nil {
+		return f, err
+	}
+	if f.Discarded, err = ts.deckTileNames(game.discardedTiles); err != nil {
+		return f, err
+	}
+
+	board := map[Pos]bool{}
+	for pos := range game.board {
+		board[pos] = true
+	}
+	for _, pos := range sortedPositions(board) {
+		tile := game.board[pos]
+		rotation, err := ts.rotation(tile)
+		if err != nil {
+			return f, err
+		}
+		saved := savedTile{toSavedPos(pos), ts.tileName(tile.id), rotation, nil}
+		if tile.meeple.playerIndex != -1 {
+			saved.Meeple = &savedMeeple{tile.meeple.playerIndex, tile.meeple.sideIndex}
+		}
+		f.Board = append(f.Board, saved)
+	}
+
+	for _, p := range game.players {
+		f.Players = append(f.Players, savedPlayer{p.score, p.meeples})
+	}
+	f.OpenPlacements = toSavedPositions(sortedPositions(game.openPlacements))
+
+	if !history {
+		return f, nil
+	}
+	for _, m := range game.lastMoves {
+		saved := savedMove{
+			Tile:              toSavedPos(m.removeTileFromBoard),
+			DeckIndex:         m.deckIndex,
+			NewOpenPlacements: toSavedPositions(m.addedNewOpenPlacements),
+			Finalization:      m.finalization,
+			CurrentPlayer:     m.currentPlayer,
+			DrawnTile:         m.drawnTile,
+		}
+		for _, r := range m.playerToBoardMeeple {
+			saved.ToBoard = append(saved.ToBoard, toSavedPlacement(r))
+		}
+		if m.boardToPlayerMeeple.playerIndex != -1 {
+			placement := toSavedPlacement(m.boardToPlayerMeeple)
+			saved.ToPlayer = &placement
+		}
+		if m.deckIndex != -1 {
+			if saved.DeckTile, err = ts.deckTileName(m.deckTile); err != nil {
+				return f, err
+			}
+		}
+		for _, p := range m.awardedPoints {
+			saved.Points = append(saved.Points, savedPoints{p.playerIndex, p.points})
+		}
+		f.LastMoves = append(f.LastMoves, saved)
+	}
+	return f, nil
+}
+
+// Writes the game as JSON. With history, all moves are saved as well, so the loaded game can reverse them.
+func (game GameState) Save(w io.Writer, history bool) error {
+	f, err := game.saveFile(history)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+func (f saveFile) validMeeple(m savedMeeple) bool {
+	return m.Player >= 0 && m.Player < len(f.Players) && m.Side >= 0 && m.Side < SIDE_FIELD+FIELD_SEGMENTS
+}
+
+func (f saveFile) game() (GameState, error) {
+	if f.Version != SAVE_VERSION {
+		return GameState{}, fmt.Errorf("unsupported save file version %v (expected %v)", f.Version, SAVE_VERSION)
+	}
+	if len(f.Players) == 0 || len(f.Players) > MAX_PLAYERS {
+		return GameState{}, fmt.Errorf("invalid number of players: %v", len(f.Players))
+	}
+
+	ts, err := f.TileSet.validate()
+	if err != nil {
+		return GameState{}, err
+	}
+	tiles, err := ts.deckTiles(f.Deck)
+	if err != nil {
+		return GameState{}, err
+	}
+
+	if f.RandomDraws > MAX_RANDOM_DRAWS {
+		return GameState{}, fmt.Errorf("too many random draws: %v (at most %v)", f.RandomDraws, MAX_RANDOM_DRAWS)
+	}
+	game := generateShuffledBoard(len(f.Players), ts.startTile, nil, f.Seed)
+	game.rngSource.skipTo(f.RandomDraws)
+	game.tiles = tiles
+	game.tileSet = ts
+	if game.discardedTiles, err = ts.deckTiles(f.Discarded); err != nil {
+		return game, err
+	}
+
+	game.board = map[Pos]Tile{}
+	for _, t := range f.Board {
+		tile, err := ts.rotatedTile(t.Tile, t.Rotation)
+		if err != nil {
+			return game, err
+		}
+		if t.Meeple != nil {
+			if !f.validMeeple(*t.Meeple) {
+				return game, fmt.Errorf("invalid meeple %v at %v", *t.Meeple, t.pos())
+			}
+			tile.meeple = Meeple{t.Meeple.Side, t.Meeple.Player}
+		}
+		if _, ok := game.board[t.pos()]; ok {
+			return game, fmt.Errorf("more than one tile at %v", t.pos())
+		}
+		game.board[t.pos()] = tile
+	}
+
+	for i, p := range f.Players {
+		game.players[i] = Player{i, p.Score, p.Meeples}
+	}
+	game.openPlacements = map[Pos]bool{}
+	for _, p := range f.OpenPlacements {
+		game.openPlacements[p.pos()] = true
+	}
+
+	if f.CurrentPlayer < 0 || f.CurrentPlayer >= len(f.Players) {
+		return game, fmt.Errorf("invalid current player %v", f.CurrentPlayer)
+	}
+	if f.DrawnTile < -1 || f.DrawnTile >= len(tiles) {
+		return game, fmt.Errorf("invalid drawn tile %v", f.DrawnTile)
+	}
+	game.currentPlayer, game.drawnTile = f.CurrentPlayer, f.DrawnTile
+
+	for i, saved := range f.LastMoves {
+		m := ReverseMove{
+			boardToPlayerMeeple: ReverseMeeplePlacement{-1, Pos{10000, 10000}, -1},
+			removeTileFromBoard: saved.Tile.pos(),
+			deckIndex:           saved.DeckIndex,
+			finalization:        saved.Finalization,
+			currentPlayer:       saved.CurrentPlayer,
+			drawnTile:           saved.DrawnTile,
+		}
+		for _, r := range saved.ToBoard {
+			if !f.validMeeple(r.savedMeeple) {
+				return game, fmt.Errorf("move %v: invalid meeple %v", i, r)
+			}
+			m.playerToBoardMeeple = append(m.playerToBoardMeeple, ReverseMeeplePlacement{r.Player, r.pos(), r.Side})
+		}
+		if r := saved.ToPlayer; r != nil {
+			if !f.validMeeple(r.savedMeeple) {
+				return game, fmt.Errorf("move %v: invalid meeple %v", i, *r)
+			}
+			m.boardToPlayerMeeple = ReverseMeeplePlacement{r.Player, r.pos(), r.Side}
+		}
+		if m.deckIndex != -1 {
+			if m.deckTile, err = ts.rotatedTile(saved.DeckTile, 0); err != nil {
+				return game, err
+			}
+		}
+		for _, p := range saved.NewOpenPlacements {
+			m.addedNewOpenPlacements = append(m.addedNewOpenPlacements, p.pos())
+		}
+		for _, p := range saved.Points {
+			if p.Player < 0 || p.Player >= len(f.Players) {
+				return game, fmt.Errorf("move %v: invalid player %v", i, p.Player)
+			}
+			m.awardedPoints = append(m.awardedPoints, ReversePlayerPoints{p.Player, p.Points})
+		}
+		game.lastMoves = append(game.lastMoves, m)
+	}
+	if err := game.validateHistory(); err != nil {
+		return game, err
+	}
+
+	return game, nil
+}
+
+// Checks that every move of a loaded history can be reversed, in the order reverseLastMove() does it:
+// The tiles and meeples of a move must be on the board, the tiles go back into the deck at valid indexes.
+func (game GameState) validateHistory() error {
+	board := map[Pos]bool{}
+	for pos := range game.board {
+		board[pos] = true
+	}
+	deckSize := len(game.tiles)
+	for i := len(game.lastMoves) - 1; i >= 0; i-- {
+		m := game.lastMoves[i]
+		if m.boardToPlayerMeeple.playerIndex != -1 && !board[m.boardToPlayerMeeple.pos] {
+			return fmt.Errorf("move %v: no tile for the meeple at %v", i, m.boardToPlayerMeeple.pos)
+		}
+		for _, r := range m.playerToBoardMeeple {
+			if !board[r.pos] {
+				return fmt.Errorf("move %v: no tile for the meeple at %v", i, r.pos)
+			}
+		}
+		if m.currentPlayer < 0 || m.currentPlayer >= len(game.players) {
+			return fmt.Errorf("move %v: invalid current player %v", i, m.currentPlayer)
+		}
+		if !m.finalization {
+			if !board[m.removeTileFromBoard] {
+				return fmt.Errorf("move %v: no tile at %v", i, m.removeTileFromBoard)
+			}
+			delete(board, m.removeTileFromBoard)
+			if m.deckIndex < -1 || m.deckIndex > deckSize {
+				return fmt.Errorf("move %v: invalid deck index %v", i, m.deckIndex)
+			}
+			if m.deckIndex != -1 {
+				deckSize++
+			}
+		}
+		if m.drawnTile < -1 || m.drawnTile >= deckSize {
+			return fmt.Errorf("move %v: invalid drawn tile %v", i, m.drawnTile)
+		}
+	}
+	return nil
+}
+
+// Reads a game written by Save(). The random number generator continues where it was when the game was saved.
+func LoadGame(r io.Reader) (GameState, error) {
+	var f saveFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return GameState{}, fmt.Errorf("invalid save file: %v", err)
+	}
+	return f.game()
+}
+
+func saveGameFile(game GameState, path string) error {
+	return writeFile(path, func(w io.Writer) error {
+		return game.Save(w, true)
+	})
+}
+
+func loadGameFile(path string) (GameState, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return GameState{}, err
+	}
+	defer f.Close()
+	return LoadGame(f)
+}
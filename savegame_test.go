@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func saveAndLoad(t *testing.T, game GameState, history bool) GameState {
+	var buf bytes.Buffer
+	if err := game.Save(&buf, history); err != nil {
+		t.Fatalf("Saving failed: %v", err)
+	}
+	loaded, err := LoadGame(&buf)
+	if err != nil {
+		t.Fatalf("Loading failed: %v", err)
+	}
+	return loaded
+}
+
+func TestSaveLoad(t *testing.T) {
+	for _, moveCount := range []int{0, 1, 30, 100} {
+		game := generateInitialBoard(3, int64(moveCount))
+		for i := 0; i < moveCount; i++ {
+			_, moves, ok := game.drawTile()
+			if !ok {
+				game.finalizeGame()
+				break
+			}
+			game.makeMove(moves[game.rng.Intn(len(moves))])
+		}
+		game.drawTile()
+
+		loaded := saveAndLoad(t, game, true)
+		if diffs := game.Diff(loaded); len(diffs) > 0 {
+			t.Fatalf("The loaded game after %v moves differs: %v", moveCount, diffs)
+		}
+
+		// The loaded history must be just as good as the original one
+		for len(game.lastMoves) > 0 {
+			game.reverseLastMove()
+			loaded.reverseLastMove()
+		}
+		if diffs := game.Diff(loaded); len(diffs) > 0 {
+			t.Fatalf("The loaded game differs after reversing all moves: %v", diffs)
+		}
+	}
+}
+
+// A loaded game continues with the same random numbers, so it is played on just like the saved one.
+func TestSaveLoadContinues(t *testing.T) {
+	config := defaultMCTSConfig()
+	config.iterations = 10
+	config.rolloutDepth = 10
+	agents := []Agent{RandomAgent{}, MCTSAgent{config}}
+
+	game := generateInitialBoard(2, 7)
+	for i := 0; i < 20; i++ {
+		tile, moves, _ := game.drawTile()
+		view := newGameView(&game, game.players[game.currentPlayer])
+		game.makeMove(agents[game.currentPlayer].ChooseMove(view, tile, moves))
+	}
+
+	loaded := saveAndLoad(t, game, true)
+	runGame(&game, agents)
+	runGame(&loaded, agents)
+	if diffs := game.Diff(loaded); len(diffs) > 0 {
+		t.Errorf("The loaded game was played differently: %v", diffs)
+	}
+}
+
+func TestSaveWithoutHistory(t *testing.T) {
+	game := generateInitialBoard(2, 3)
+	runGame(&game, []Agent{FirstMoveAgent{}, GreedyAgent{}})
+
+	loaded := saveAndLoad(t, game, false)
+	if len(loaded.lastMoves) != 0 {
+		t.Errorf("Expected no moves, got %v", len(loaded.lastMoves))
+	}
+	loaded.lastMoves = game.lastMoves
+	if diffs := game.Diff(loaded); len(diffs) > 0 {
+		t.Errorf("The loaded game differs: %v", diffs)
+	}
+
+	var a, b bytes.Buffer
+	game.Save(&a, false)
+	loaded.Save(&b, false)
+	if a.String() != b.String() {
+		t.Errorf("Saving the same game twice should give the same file")
+	}
+}
+
+func TestLoadInvalidGames(t *testing.T) {
+	var buf bytes.Buffer
+	generateInitialBoard(2, 0).Save(&buf, false)
+	valid := buf.String()
+
+	cases := []struct {
+		name    string
+		replace [2]string
+		error   string
+	}{
+		{"version", [2]string{`"version": 1`, `"version": 99`}, "version"},
+		{"tile", [2]string{`"tile": "D"`, `"tile": "Unknown"`}, "unknown tile"},
+		{"rotation", [2]string{`"rotation": 0`, `"rotation": 4`}, "rotation"},
+		{"player", [2]string{`"currentPlayer": 0`, `"currentPlayer": 2`}, "current player"},
+		{"json", [2]string{`{`, `[`}, "invalid save file"},
+	}
+	for _, c := range cases {
+		data := strings.Replace(valid, c.replace[0], c.replace[1], 1)
+		if data == valid {
+			t.Fatalf("%v: the test case doesn't change the save file", c.name)
+		}
+		_, err := LoadGame(strings.NewReader(data))
+		if err == nil || !strings.Contains(err.Error(), c.error) {
+			t.Errorf("%v: expected an error containing %q, got %v", c.name, c.error, err)
+		}
+	}
+}
+
+// A corrupt history must be rejected when loading, not crash reverseLastMove() later.
+func TestLoadInvalidHistory(t *testing.T) {
+	cases := []struct {
+		name   string
+		change func(game *GameState)
+		error  string
+	}{
+		{"deck index", func(game *GameState) { game.lastMoves[1].deckIndex = len(game.tiles) + 2 }, "deck index"},
+		{"tile", func(game *GameState) { game.lastMoves[0].removeTileFromBoard = Pos{5, 5} }, "no tile at"},
+		{"player", func(game *GameState) { game.lastMoves[0].currentPlayer = 2 }, "current player"},
+		{"drawn tile", func(game *GameState) { game.lastMoves[1].drawnTile = len(game.tiles) + 1 }, "drawn tile"},
+		{"random draws", func(game *GameState) { game.rngSource.count = MAX_RANDOM_DRAWS + 1 }, "random draws"},
+	}
+	for _, c := range cases {
+		game := generateInitialBoard(2, 0)
+		for i := 0; i < 2; i++ {
+			_, moves, _ := game.drawTile()
+			game.makeMove(moves[0])
+		}
+		c.change(&game)
+
+		var buf bytes.Buffer
+		if err := game.Save(&buf, true); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadGame(&buf)
+		if err == nil || !strings.Contains(err.Error(), c.error) {
+			t.Errorf("%v: expected an error containing %q, got %v", c.name, c.error, err)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hosts many games behind a JSON API:
+//
+//	GET    /games                 ids of all games
+//	POST   /games                 creates a game, see createGameRequest
+//	GET    /games/<id>            state of the game
+//	DELETE /games/<id>            removes the game
+//	GET    /games/<id>/moves      legal moves of the drawn tile
+//	POST   /games/<id>/moves      makes a move, see moveRequest
+//	POST   /games/<id>/undo       takes back the last move
+//	GET    /games/<id>/events     WebSocket with the events of the game, see events.go
+//
+// The next tile is drawn right after every move, so a game always has a drawn tile until the deck is
+// empty. Then the game is scored and finished. Errors are returned as {"error": "..."}.
+type gameServer struct {
+	// Protects games and nextId. Every game has its own mutex, so requests for different games don't wait for each other
+	mutex    sync.Mutex
+	games    map[string]*serverGame
+	nextId   int
+	tileSets map[string]TileSet
+}
+
+type serverGame struct {
+	// GameState is not safe for concurrent use, so every access to game goes through the mutex
+	mutex sync.Mutex
+	id    string
+	game  GameState
+	// The drawn tile and its moves
+	tile  Tile
+	moves []Move
+	// Number of discarded tiles before each move and before the current draw, to put them back on undo
+	discarded     []int
+	drawDiscarded int
+	// Clients of the event stream, see events.go
+	listeners map[chan []byte]bool
+}
+
+type createGameRequest struct {
+	Players int `json:"players"`
+	// A random seed is picked if 0
+	Seed int64 `json:"seed"`
+	// The base tile set if empty
+	TileSet    string   `json:"tileSet"`
+	Expansions []string `json:"expansions"`
+}
+
+type moveRequest struct {
+	// The move in notation, like "D r1 @-1,0 M:up"
+	Move string `json:"move"`
+}
+
+type gameResponse struct {
+	Id             string        `json:"id"`
+	Seed           int64         `json:"seed"`
+	TileSet        string        `json:"tileSet"`
+	Players        []savedPlayer `json:"players"`
+	CurrentPlayer  int           `json:"currentPlayer"`
+	DrawnTile      string        `json:"drawnTile,omitempty"`
+	TilesLeft      int           `json:"tilesLeft"`
+	Board          []savedTile   `json:"board"`
+	OpenPlacements []savedPos    `json:"openPlacements"`
+	// All moves made so far in notation
+	Moves    []string `json:"moves"`
+	Finished bool     `json:"finished"`
+	Winners  []int    `json:"winners,omitempty"`
+}
+
+type moveResponse struct {
+	savedPos
+	Notation string       `json:"notation"`
+	Rotation int          `json:"rotation"`
+	Meeple   *savedMeeple `json:"meeple,omitempty"`
+}
+
+type movesResponse struct {
+	Tile  string         `json:"tile"`
+	Moves []moveResponse `json:"moves"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// The base tile set is always available.
+func newGameServer(tileSets ...TileSet) *gameServer {
+	s := &gameServer{games: map[string]*serverGame{}, tileSets: map[string]TileSet{}}
+	for _, ts := range append([]TileSet{baseTileSet()}, tileSets...) {
+		s.tileSets[ts.name] = ts
+	}
+	return s
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, errorResponse{fmt.Sprintf(format, args...)})
+}
+
+func (s *gameServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "games" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, "unknown path %v", r.URL.Path)
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, s.gameIds())
+		case http.MethodPost:
+			s.createGame(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method %v is not allowed", r.Method)
+		}
+		return
+	}
+
+	s.mutex.Lock()
+	g, ok := s.games[parts[1]]
+	s.mutex.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown game %q", parts[1])
+		return
+	}
+
+	action := ""
+	if len(parts) == 3 {
+		action = parts[2]
+	}
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		g.writeState(w, http.StatusOK)
+	case action == "" && r.Method == http.MethodDelete:
+		s.mutex.Lock()
+		delete(s.games, g.id)
+		s.mutex.Unlock()
+		g.mutex.Lock()
+		g.closeListeners()
+		g.mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case action == "moves" && r.Method == http.MethodGet:
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		g.writeMoves(w)
+	case action == "moves" && r.Method == http.MethodPost:
+		var req moveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request: %v", err)
+			return
+		}
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		g.makeMove(w, req.Move)
+	case action == "undo" && r.Method == http.MethodPost:
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		g.undo(w)
+	case action == "events" && r.Method == http.MethodGet:
+		g.streamEvents(w, r)
+	case action == "" || action == "moves" || action == "undo" || action == "events":
+		writeError(w, http.StatusMethodNotAllowed, "method %v is not allowed", r.Method)
+	default:
+		writeError(w, http.StatusNotFound, "unknown path %v", r.URL.Path)
+	}
+}
+
+func (s *gameServer) gameIds() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ids := []string{}
+	for id := range s.games {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+	return ids
+}
+
+func (s *gameServer) createGame(w http.ResponseWriter, r *http.Request) {
+	var req createGameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: %v", err)
+		return
+	}
+	if req.Players < 1 || req.Players > MAX_PLAYERS {
+		writeError(w, http.StatusBadRequest, "the number of players must be between 1 and %v", MAX_PLAYERS)
+		return
+	}
+	if req.TileSet == "" {
+		req.TileSet = "base"
+	}
+	tileSet, ok := s.tileSets[req.TileSet]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "unknown tile set %q", req.TileSet)
+		return
+	}
+	if req.Seed == 0 {
+		req.Seed = time.Now().UnixNano()
+	}
+
+	g := &serverGame{game: generateTileSetBoard(req.Players, tileSet, req.Expansions, req.Seed), listeners: map[chan []byte]bool{}}
+	g.draw()
+
+	s.mutex.Lock()
+	s.nextId++
+	g.id = strconv.Itoa(s.nextId)
+	s.games[g.id] = g
+	s.mutex.Unlock()
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.writeState(w, http.StatusCreated)
+}
+
+func (g *serverGame) finished() bool {
+	moves := g.game.lastMoves
+	return len(moves) > 0 && moves[len(moves)-1].finalization
+}
+
+// Draws the next tile or finishes the game if there is none.
+func (g *serverGame) draw() {
+	g.drawDiscarded = len(g.game.discardedTiles)
+	var ok bool
+	if g.tile, g.moves, ok = g.game.drawTile(); !ok {
+		g.game.finalizeGame()
+	}
+}
+
+func (g *serverGame) state() (gameResponse, error) {
+	f, err := g.game.saveFile(false)
+	if err != nil {
+		return gameResponse{}, err
+	}
+	res := gameResponse{
+		Id:             g.id,
+		Seed:           g.game.seed,
+		TileSet:        g.game.tileSet.name,
+		Players:        f.Players,
+		CurrentPlayer:  g.game.currentPlayer,
+		TilesLeft:      len(g.game.tiles),
+		Board:          f.Board,
+		OpenPlacements: f.OpenPlacements,
+		Moves:          []string{},
+		Finished:       g.finished(),
+	}
+	if g.game.drawnTile != -1 {
+		res.DrawnTile = g.game.tileSet.tileName(g.tile.id)
+	}
+	for _, move := range g.game.madeMoves() {
+		notation, err := g.game.moveNotation(move)
+		if err != nil {
+			return res, err
+		}
+		res.Moves = append(res.Moves, notation)
+	}
+	if res.Finished {
+		res.Winners = g.game.winners()
+	}
+	return res, nil
+}
+
+func (g *serverGame) writeState(w http.ResponseWriter, status int) {
+	res, err := g.state()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	writeJSON(w, status, res)
+}
+
+func (g *serverGame) writeMoves(w http.ResponseWriter) {
+	res := movesResponse{Moves: []moveResponse{}}
+	if g.game.drawnTile != -1 {
+		res.Tile = g.game.tileSet.tileName(g.tile.id)
+	}
+	for _, move := range g.moves {
+		notation, err := g.game.moveNotation(move)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+		rotation, _ := g.game.tileSet.rotation(move.tile)
+		m := moveResponse{toSavedPos(move.pos), notation, rotation, nil}
+		if move.tile.meeple.playerIndex != -1 {
+			m.Meeple = &savedMeeple{move.tile.meeple.playerIndex, move.tile.meeple.sideIndex}
+		}
+		res.Moves = append(res.Moves, m)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (g *serverGame) makeMove(w http.ResponseWriter, notation string) {
+	if g.finished() {
+		writeError(w, http.StatusConflict, "the game is finished")
+		return
+	}
+	move, err := g.game.parseMove(notation)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	g.discarded = append(g.discarded, g.drawDiscarded)
+	g.game.makeMove(move)
+	g.moves = nil
+	events := g.moveEvents(move)
+	g.draw()
+	g.publish(append(events, g.drawEvents()...)...)
+	g.writeState(w, http.StatusOK)
+}
+
+func (g *serverGame) undo(w http.ResponseWriter) {
+	if len(g.discarded) == 0 {
+		writeError(w, http.StatusConflict, "there is no move to take back")
+		return
+	}
+	if g.finished() {
+		g.game.reverseLastMove()
+	}
+	g.game.restoreDiscardedTiles(g.drawDiscarded)
+	g.game.reverseLastMove()
+	g.game.restoreDiscardedTiles(g.discarded[len(g.discarded)-1])
+	g.discarded = g.discarded[:len(g.discarded)-1]
+	g.draw()
+	g.publishState()
+	g.writeState(w, http.StatusOK)
+}
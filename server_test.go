@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// Sends the request and decodes the JSON response into result, if given. Returns the status code.
+func request(t *testing.T, method, url string, body interface{}, result interface{}) int {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if result != nil && res.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+			t.Fatalf("%v %v: invalid response: %v", method, url, err)
+		}
+	}
+	return res.StatusCode
+}
+
+func createTestGame(t *testing.T, url string, players int, seed int64) gameResponse {
+	var state gameResponse
+	if status := request(t, "POST", url+"/games", createGameRequest{Players: players, Seed: seed}, &state); status != http.StatusCreated {
+		t.Fatalf("Creating the game failed with %v", status)
+	}
+	return state
+}
+
+func TestServerGame(t *testing.T) {
+	server := httptest.NewServer(newGameServer())
+	defer server.Close()
+
+	start := createTestGame(t, server.URL, 2, 3)
+	gameUrl := server.URL + "/games/" + start.Id
+	game := generateInitialBoard(2, 3)
+	tile, moves, _ := game.drawTile()
+
+	if start.Seed != 3 || len(start.Players) != 2 || len(start.Board) != 1 || start.Finished {
+		t.Errorf("Unexpected new game: %+v", start)
+	}
+	if name := game.tileSet.tileName(tile.id); start.DrawnTile != name {
+		t.Errorf("Expected the drawn tile %v, got %v", name, start.DrawnTile)
+	}
+
+	var legal movesResponse
+	if status := request(t, "GET", gameUrl+"/moves", nil, &legal); status != http.StatusOK {
+		t.Fatalf("Getting the moves failed with %v", status)
+	}
+	if len(legal.Moves) != len(moves) {
+		t.Fatalf("Expected %v moves, got %v", len(moves), len(legal.Moves))
+	}
+	for i, m := range moves {
+		if notation, _ := game.moveNotation(m); legal.Moves[i].Notation != notation {
+			t.Errorf("Expected move %v, got %v", notation, legal.Moves[i].Notation)
+		}
+	}
+
+	var state gameResponse
+	if status := request(t, "POST", gameUrl+"/moves", moveRequest{legal.Moves[0].Notation}, &state); status != http.StatusOK {
+		t.Fatalf("The move failed with %v", status)
+	}
+	if len(state.Board) != 2 || state.CurrentPlayer != 1 || !reflect.DeepEqual(state.Moves, []string{legal.Moves[0].Notation}) {
+		t.Errorf("The move wasn't made: %+v", state)
+	}
+
+	request(t, "GET", gameUrl, nil, &state)
+	if len(state.Moves) != 1 {
+		t.Errorf("Expected one move, got %v", state.Moves)
+	}
+
+	if status := request(t, "POST", gameUrl+"/undo", nil, &state); status != http.StatusOK {
+		t.Fatalf("Undo failed with %v", status)
+	}
+	if !reflect.DeepEqual(state, start) {
+		t.Errorf("Undo should restore the start:\n%+v\n%+v", state, start)
+	}
+
+	if status := request(t, "DELETE", gameUrl, nil, nil); status != http.StatusNoContent {
+		t.Errorf("Deleting the game failed with %v", status)
+	}
+	if status := request(t, "GET", gameUrl, nil, nil); status != http.StatusNotFound {
+		t.Errorf("The game should be deleted, got %v", status)
+	}
+}
+
+func TestServerFullGame(t *testing.T) {
+	server := httptest.NewServer(newGameServer())
+	defer server.Close()
+
+	state := createTestGame(t, server.URL, 3, 107)
+	gameUrl := server.URL + "/games/" + state.Id
+	for !state.Finished {
+		var legal movesResponse
+		request(t, "GET", gameUrl+"/moves", nil, &legal)
+		// Fields left out of the response keep their value otherwise
+		state = gameResponse{}
+		if status := request(t, "POST", gameUrl+"/moves", moveRequest{legal.Moves[0].Notation}, &state); status != http.StatusOK {
+			t.Fatalf("The move failed with %v", status)
+		}
+	}
+
+	// The same game without the server
+	game := generateInitialBoard(3, 107)
+	runGame(&game, []Agent{FirstMoveAgent{}, FirstMoveAgent{}, FirstMoveAgent{}})
+	for i, p := range game.players {
+		if state.Players[i].Score != p.score {
+			t.Errorf("Player %v: expected %v points, got %v", i, p.score, state.Players[i].Score)
+		}
+	}
+	if !reflect.DeepEqual(state.Winners, game.winners()) || state.DrawnTile != "" || state.TilesLeft != 0 {
+		t.Errorf("Unexpected end of the game: %+v", state)
+	}
+
+	var e errorResponse
+	if status := request(t, "POST", gameUrl+"/moves", moveRequest{"A r0 @5,5"}, &e); status != http.StatusConflict {
+		t.Errorf("Moves in a finished game should fail, got %v: %v", status, e)
+	}
+
+	// Undo takes back the final scoring and the last move
+	var undone gameResponse
+	request(t, "POST", gameUrl+"/undo", nil, &undone)
+	if undone.Finished || len(undone.Moves) != len(state.Moves)-1 || undone.DrawnTile == "" {
+		t.Errorf("Undo should reopen the game: %+v", undone)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	server := httptest.NewServer(newGameServer())
+	defer server.Close()
+	state := createTestGame(t, server.URL, 2, 3)
+	gameUrl := server.URL + "/games/" + state.Id
+
+	tests := []struct {
+		method, url string
+		body        interface{}
+		status      int
+	}{
+		{"POST", server.URL + "/games", createGameRequest{Players: 0}, http.StatusBadRequest},
+		{"POST", server.URL + "/games", createGameRequest{Players: MAX_PLAYERS + 1}, http.StatusBadRequest},
+		{"POST", server.URL + "/games", createGameRequest{Players: 2, TileSet: "unknown"}, http.StatusBadRequest},
+		{"POST", server.URL + "/games", "no object", http.StatusBadRequest},
+		{"PUT", server.URL + "/games", nil, http.StatusMethodNotAllowed},
+		{"GET", server.URL + "/nothing", nil, http.StatusNotFound},
+		{"GET", server.URL + "/games/1000", nil, http.StatusNotFound},
+		{"GET", gameUrl + "/nothing", nil, http.StatusNotFound},
+		{"GET", gameUrl + "/undo", nil, http.StatusMethodNotAllowed},
+		{"POST", gameUrl + "/undo", nil, http.StatusConflict},
+		{"POST", gameUrl + "/moves", moveRequest{"nonsense"}, http.StatusBadRequest},
+		{"POST", gameUrl + "/moves", moveRequest{state.DrawnTile + " r0 @5,5"}, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		var e errorResponse
+		if status := request(t, test.method, test.url, test.body, &e); status != test.status || e.Error == "" {
+			t.Errorf("%v %v: expected %v with an error, got %v %q", test.method, test.url, test.status, status, e.Error)
+		}
+	}
+}
+
+// Plays several games at the same time, while others watch them.
+func TestServerConcurrentGames(t *testing.T) {
+	server := httptest.NewServer(newGameServer())
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		state := createTestGame(t, server.URL, 2, int64(i+1))
+		gameUrl := server.URL + "/games/" + state.Id
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				var legal movesResponse
+				request(t, "GET", gameUrl+"/moves", nil, &legal)
+				request(t, "POST", gameUrl+"/moves", moveRequest{legal.Moves[len(legal.Moves)-1].Notation}, nil)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				var state gameResponse
+				if status := request(t, "GET", gameUrl, nil, &state); status != http.StatusOK {
+					t.Errorf("Getting the state failed with %v", status)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var ids []string
+	request(t, "GET", server.URL+"/games", nil, &ids)
+	if fmt.Sprint(ids) != "[1 2 3 4]" {
+		t.Errorf("Expected four games, got %v", ids)
+	}
+	for _, id := range ids {
+		var state gameResponse
+		request(t, "GET", server.URL+"/games/"+id, nil, &state)
+		if len(state.Moves) != 20 {
+			t.Errorf("Game %v: expected 20 moves, got %v", id, len(state.Moves))
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Returns a human readable list of all differences between two game states. Compares the board,
+// the tiles, the players, the open placements, the move history, the seed and whose turn it is.
+// The structure registry is not compared, it is only a cache of the board.
+func (game GameState) Diff(other GameState) (diffs []string) {
+
+	for pos, t := range game.board {
+		if o, ok := other.board[pos]; !ok {
+			diffs = append(diffs, fmt.Sprintf("board: %v only in the first state", pos))
+		} else if t != o {
+			diffs = append(diffs, fmt.Sprintf("board: %v is %v != %v", pos, t, o))
+		}
+	}
+	for pos := range other.board {
+		if _, ok := game.board[pos]; !ok {
+			diffs = append(diffs, fmt.Sprintf("board: %v only in the second state", pos))
+		}
+	}
+
+	diffs = append(diffs, diffTiles("tiles", game.tiles, other.tiles)...)
+	diffs = append(diffs, diffTiles("discarded tiles", game.discardedTiles, other.discardedTiles)...)
+
+	if len(game.players) != len(other.players) {
+		diffs = append(diffs, fmt.Sprintf("players: %v players != %v players", len(game.players), len(other.players)))
+	} else {
+		for i := range game.players {
+			if game.players[i] != other.players[i] {
+				diffs = append(diffs, fmt.Sprintf("players: %v != %v", game.players[i], other.players[i]))
+			}
+		}
+	}
+
+	for _, pos := range sortedPositions(game.openPlacements) {
+		if !other.openPlacements[pos] {
+			diffs = append(diffs, fmt.Sprintf("openPlacements: %v only in the first state", pos))
+		}
+	}
+	for _, pos := range sortedPositions(other.openPlacements) {
+		if !game.openPlacements[pos] {
+			diffs = append(diffs, fmt.Sprintf("openPlacements: %v only in the second state", pos))
+		}
+	}
+
+	if len(game.lastMoves) != len(other.lastMoves) {
+		diffs = append(diffs, fmt.Sprintf("lastMoves: %v moves != %v moves", len(game.lastMoves), len(other.lastMoves)))
+	} else {
+		for i := range game.lastMoves {
+			// The marks of the structure registry are only valid for the registry that made them
+			a, b := game.lastMoves[i], other.lastMoves[i]
+			a.structures, b.structures = structureMark{}, structureMark{}
+			if !reflect.DeepEqual(a, b) {
+				diffs = append(diffs, fmt.Sprintf("lastMoves: move %v is %v != %v", i, game.lastMoves[i], other.lastMoves[i]))
+			}
+		}
+	}
+
+	if game.currentPlayer != other.currentPlayer {
+		diffs = append(diffs, fmt.Sprintf("currentPlayer: %v != %v", game.currentPlayer, other.currentPlayer))
+	}
+	if game.seed != other.seed {
+		diffs = append(diffs, fmt.Sprintf("seed: %v != %v", game.seed, other.seed))
+	}
+	if game.drawnTile != other.drawnTile {
+		diffs = append(diffs, fmt.Sprintf("drawnTile: %v != %v", game.drawnTile, other.drawnTile))
+	}
+
+	return
+}
+
+func diffTiles(name string, a, b []Tile) (diffs []string) {
+	if len(a) != len(b) {
+		return []string{fmt.Sprintf("%v: %v tiles != %v tiles", name, len(a), len(b))}
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			diffs = append(diffs, fmt.Sprintf("%v: tile %v is %v != %v", name, i, a[i], b[i]))
+		}
+	}
+	return
+}
+
+func (game GameState) Equal(other GameState) bool {
+	return len(game.Diff(other)) == 0
+}
+
+// Returns a copy of the game state that shares nothing with the original, except for the random number generator.
+// The structure registry is not copied and will be rebuilt when needed.
+func (game GameState) copy() GameState {
+	c := game
+	c.board = make(map[Pos]Tile, len(game.board))
+	for pos, t := range game.board {
+		c.board[pos] = t
+	}
+	c.tiles = append([]Tile{}, game.tiles...)
+	c.discardedTiles = append([]Tile{}, game.discardedTiles...)
+	c.players = append([]Player{}, game.players...)
+	c.openPlacements = make(map[Pos]bool, len(game.openPlacements))
+	for pos := range game.openPlacements {
+		c.openPlacements[pos] = true
+	}
+	c.lastMoves = append([]ReverseMove{}, game.lastMoves...)
+	c.structures = nil
+	return c
+}
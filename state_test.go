@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	game := generateInitialBoard(2, 0)
+	_, moves, _ := game.drawTile()
+	other := game.copy()
+	if !game.Equal(other) {
+		t.Fatalf("A copy should be equal: %v", game.Diff(other))
+	}
+
+	other.makeMove(moves[0])
+	// board, tiles, openPlacements, lastMoves, currentPlayer and maybe the players score
+	if diffs := game.Diff(other); len(diffs) < 5 {
+		t.Errorf("Expected at least 5 differences, got %v", diffs)
+	}
+	other.reverseLastMove()
+	if !game.Equal(other) {
+		t.Errorf("Reversing the move should restore the state: %v", game.Diff(other))
+	}
+}
+
+// Plays random legal moves, reverses all of them and expects the original game state.
+func FuzzReverseMoves(f *testing.F) {
+	f.Add(int64(0), uint8(3), uint8(10), false)
+	f.Add(int64(1), uint8(2), uint8(71), true)
+	f.Add(int64(42), uint8(5), uint8(40), false)
+
+	f.Fuzz(func(t *testing.T, seed int64, players uint8, moveCount uint8, finalize bool) {
+		game := generateInitialBoard(2+int(players)%(MAX_PLAYERS-1), seed)
+		r := game.rng
+		original := game.copy()
+
+		moves := 0
+		for ; moves < int(moveCount) && len(game.tiles) > 0; moves++ {
+			// Draw any tile of the deck. A discarded tile could not be reversed, so the game just stops there
+			game.drawnTile = r.Intn(len(game.tiles))
+			possible := generatePossibleMoves(game.board, []Tile{game.tiles[game.drawnTile]}, game.openPlacements, game.players[game.currentPlayer], len(game.players))
+			if len(possible) == 0 {
+				break
+			}
+			game.makeMove(possible[r.Intn(len(possible))])
+		}
+		if finalize {
+			game.finalizeGame()
+			moves++
+		}
+		for i := 0; i < moves; i++ {
+			game.reverseLastMove()
+		}
+		game.drawnTile = -1
+
+		if diffs := original.Diff(game); len(diffs) > 0 {
+			t.Errorf("The game state changed after reversing %v moves: %v", moves, diffs)
+		}
+	})
+}
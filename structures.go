@@ -0,0 +1,284 @@
+package main
+
+import (
+	"sync/atomic"
+)
+
+// Roads and cities are tracked incrementally with a union-find over all (pos, side) segments on the board.
+// Every group of connected sides on a tile is one node. Nodes are merged when tiles are placed next to
+// each other, so the root of a node always knows the whole structure: tile count, emblems, open edges
+// and meeples per player. That way closing checks and scoring only need to find the root instead of a walk
+// over the structure.
+//
+// There is no path compression, so every change can be undone in reverse order. Finding a root is still
+// O(log n), as the smaller structure is always merged into the bigger one. placeTile() stores a
+// structureMark in the ReverseMove and reverseLastMove() rolls everything back to it.
+
+// The registry keeps fixed size meeple counts per structure, so undoing a change is a simple copy.
+const MAX_PLAYERS = 6
+
+type structureNode struct {
+	parent int
+	// The following are only valid for root nodes.
+	area Area
+	size int
+	// Every tile only counts once, even if the structure goes through it more than once.
+	tiles     int
+	emblems   int
+	openEdges int
+	meeples   [MAX_PLAYERS]int
+}
+
+// A tile with more than one road or city of the same area, like the two road pairs of TierI.
+// A structure can go through it more than once.
+type repeatedTile struct {
+	nodes  []int
+	emblem bool
+}
+
+type structureChange struct {
+	node int
+	old  structureNode
+}
+
+type structureMark struct {
+	generation int64
+	nodes      int
+	changes    int
+	segments   int
+	repeated   int
+	tileCount  int
+}
+
+type structureRegistry struct {
+	nodes    []structureNode
+	segment  map[Visited]int
+	changes  []structureChange
+	segments []Visited
+	repeated []repeatedTile
+	// Number of tiles that were added.
+	tileCount int
+	// Unique for every registry, so old marks can not be used after a rebuild.
+	generation int64
+}
+
+// Games might run in parallel, so this is only accessed atomically.
+var g_structureGeneration int64
+
+func newStructureRegistry(board map[Pos]Tile) *structureRegistry {
+	r := &structureRegistry{segment: map[Visited]int{}, generation: atomic.AddInt64(&g_structureGeneration, 1)}
+	for pos, tile := range board {
+		r.addTile(pos, tile)
+	}
+	return r
+}
+
+// Returns the registry of the game. It is built from scratch on first use and after invalidateStructures().
+// placeTile() and reverseLastMove() keep it in sync with the board.
+func (game *GameState) structureRegistry() *structureRegistry {
+	if game.structures == nil {
+		game.structures = newStructureRegistry(game.board)
+	}
+	return game.structures
+}
+
+// Must be called after the board was changed without placeTile() or reverseLastMove(), for example
+// by setting up a board by hand.
+func (game *GameState) invalidateStructures() {
+	game.structures = nil
+}
+
+func (r *structureRegistry) mark() structureMark {
+	return structureMark{r.generation, len(r.nodes), len(r.changes), len(r.segments), len(r.repeated), r.tileCount}
+}
+
+// Undoes all changes since the mark was taken. Returns false, if the mark belongs to an older registry.
+func (r *structureRegistry) rollback(m structureMark) bool {
+	if m.generation != r.generation {
+		return false
+	}
+	for i := len(r.changes) - 1; i >= m.changes; i-- {
+		r.nodes[r.changes[i].node] = r.changes[i].old
+	}
+	r.changes = r.changes[:m.changes]
+
+	for _, s := range r.segments[m.segments:] {
+		delete(r.segment, s)
+	}
+	r.segments = r.segments[:m.segments]
+	r.repeated = r.repeated[:m.repeated]
+
+	r.tileCount = m.tileCount
+	r.nodes = r.nodes[:m.nodes]
+	return true
+}
+
+// Every change of an existing node must go through here, so it can be rolled back.
+func (r *structureRegistry) set(i int, node structureNode) {
+	r.changes = append(r.changes, structureChange{i, r.nodes[i]})
+	r.nodes[i] = node
+}
+
+func (r *structureRegistry) find(i int) int {
+	for r.nodes[i].parent != i {
+		i = r.nodes[i].parent
+	}
+	return i
+}
+
+func (r *structureRegistry) union(a, b int) int {
+	a, b = r.find(a), r.find(b)
+	if a == b {
+		return a
+	}
+	if r.nodes[a].size < r.nodes[b].size {
+		a, b = b, a
+	}
+
+	// Tiles that are part of both structures would be counted twice
+	tiles, emblems := 0, 0
+	for _, t := range r.repeated {
+		inA, inB := false, false
+		for _, n := range t.nodes {
+			root := r.find(n)
+			inA = inA || root == a
+			inB = inB || root == b
+		}
+		if inA && inB {
+			tiles++
+			if t.emblem {
+				emblems++
+			}
+		}
+	}
+
+	root, child := r.nodes[a], r.nodes[b]
+	root.size += child.size
+	root.tiles += child.tiles - tiles
+	root.emblems += child.emblems - emblems
+	root.openEdges += child.openEdges
+	for p := range root.meeples {
+		root.meeples[p] += child.meeples[p]
+	}
+	child.parent = a
+
+	r.set(a, root)
+	r.set(b, child)
+	return a
+}
+
+// Returns the root of the structure at the given side. ok is false, if there is no road or city.
+func (r *structureRegistry) structure(pos Pos, side int) (int, bool) {
+	i, ok := r.segment[Visited{pos, side}]
+	if !ok {
+		return -1, false
+	}
+	return r.find(i), true
+}
+
+func (r *structureRegistry) addTile(pos Pos, tile Tile) {
+	// The number of nodes is not the number of tiles. Tiles without roads or cities still need to be counted.
+	r.tileCount++
+
+	// All nodes of the tile are created first, so repeatedTile knows all of them before any merge
+	nodes := [4]int{-1, -1, -1, -1}
+	areaNodes := map[Area][]int{}
+	for side := 0; side < 4; side++ {
+		if nodes[side] != -1 || tile.sides[side] == AREA_GRASS {
+			continue
+		}
+
+		node := len(r.nodes)
+		n := structureNode{parent: node, area: tile.sides[side], size: 1, tiles: 1}
+		if tile.emblem && n.area == AREA_CITY {
+			n.emblems = 1
+		}
+		r.nodes = append(r.nodes, n)
+		areaNodes[n.area] = append(areaNodes[n.area], node)
+
+		for s := side; s < 4; s++ {
+			if nodes[s] != -1 || tile.sides[s] != tile.sides[side] || !tile.isConnected(side, s) {
+				continue
+			}
+			nodes[s] = node
+			r.segment[Visited{pos, s}] = node
+			r.segments = append(r.segments, Visited{pos, s})
+		}
+	}
+	for _, area := range []Area{AREA_ROAD, AREA_CITY} {
+		if len(areaNodes[area]) > 1 {
+			r.repeated = append(r.repeated, repeatedTile{areaNodes[area], tile.emblem && area == AREA_CITY})
+		}
+	}
+
+	for s, node := range nodes {
+		if node == -1 {
+			continue
+		}
+		other, ok := r.structure(add(pos, g_sides[s]), (s+2)%4)
+		if !ok {
+			root := r.find(node)
+			tmp := r.nodes[root]
+			tmp.openEdges++
+			r.set(root, tmp)
+			continue
+		}
+		// The open edge of the neighbour is now closed by this tile
+		tmp := r.nodes[other]
+		tmp.openEdges--
+		r.set(other, tmp)
+		r.union(node, other)
+	}
+
+	if tile.meeple.playerIndex != -1 {
+		r.addMeeple(pos, tile.meeple, 1)
+	}
+}
+
+// Adds (count == 1) or removes (count == -1) a meeple. Meeples on cloisters and fields are ignored.
+func (r *structureRegistry) addMeeple(pos Pos, meeple Meeple, count int) {
+	if meeple.sideIndex < 0 || meeple.sideIndex > 3 {
+		return
+	}
+	root, ok := r.structure(pos, meeple.sideIndex)
+	if !ok {
+		return
+	}
+	tmp := r.nodes[root]
+	tmp.meeples[meeple.playerIndex] += count
+	r.set(root, tmp)
+}
+
+// The points of a structure, if it was scored right now. Closed cities count twice.
+func (n structureNode) points() int {
+	points := n.tiles
+	if n.area == AREA_CITY {
+		points += n.emblems
+		if n.openEdges == 0 {
+			points *= 2
+		}
+	}
+	return points
+}
+
+func (n structureNode) hasMeeples() bool {
+	for _, count := range n.meeples {
+		if count > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns all positions with a meeple on the road or city at the given side.
+func structureMeeplePositions(board map[Pos]Tile, pos Pos, side int) (positions []Pos) {
+	segments := map[Visited]bool{}
+	structureSegments(board, pos, side, segments)
+	tiles := map[Pos]bool{}
+	for v := range segments {
+		if t := board[v.pos]; t.meeple.playerIndex != -1 && t.meeple.sideIndex == v.side {
+			tiles[v.pos] = true
+		}
+	}
+	return sortedPositions(tiles)
+}
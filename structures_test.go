@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Compares every road and city in the registry with a full walk over the structure.
+func checkStructures(t *testing.T, game *GameState) {
+	structures := game.structureRegistry()
+
+	for pos, tile := range game.board {
+		for side := 0; side < 4; side++ {
+			root, ok := structures.structure(pos, side)
+			if ok != (tile.sides[side] != AREA_GRASS) {
+				t.Fatalf("Segment %v %v should be registered: %v", pos, side, ok)
+			}
+			if !ok {
+				continue
+			}
+
+			segments := map[Visited]bool{}
+			closed := structureSegments(game.board, pos, side, segments)
+
+			tiles := map[Pos]bool{}
+			var meeples [MAX_PLAYERS]int
+			for v := range segments {
+				t := game.board[v.pos]
+				tiles[v.pos] = true
+				if t.meeple.playerIndex != -1 && t.meeple.sideIndex == v.side {
+					meeples[t.meeple.playerIndex] += 1
+				}
+			}
+
+			structure := structures.nodes[root]
+			if (structure.openEdges == 0) != closed {
+				t.Fatalf("Structure at %v %v: closed is %v but the registry has %v open edges", pos, side, closed, structure.openEdges)
+			}
+			if structure.tiles != len(tiles) {
+				t.Fatalf("Structure at %v %v has %v tiles but the registry counts %v", pos, side, len(tiles), structure.tiles)
+			}
+			if structure.meeples != meeples {
+				t.Fatalf("Structure at %v %v has meeples %v but the registry has %v", pos, side, meeples, structure.meeples)
+			}
+		}
+	}
+}
+
+func TestStructureRegistry(t *testing.T) {
+	game := generateInitialBoard(3, 0)
+	start := game.structureRegistry().mark()
+
+	moveCount := 0
+	for i, tile := range game.tiles {
+		player := game.players[i%len(game.players)]
+
+		moves := generatePossibleMoves(game.board, []Tile{tile}, game.openPlacements, player, len(game.players))
+		if len(moves) > 0 {
+			game.makeMove(moves[rand.Intn(len(moves))])
+			moveCount += 1
+			checkStructures(t, &game)
+		}
+	}
+
+	generation := game.structures.generation
+	for i := 0; i < moveCount; i++ {
+		game.reverseLastMove()
+		checkStructures(t, &game)
+	}
+
+	if game.structures.generation != generation {
+		t.Errorf("Reversing moves should roll back the registry instead of rebuilding it")
+	}
+	if end := game.structures.mark(); end != start {
+		t.Errorf("Only the start tile should be left in the registry: %v != %v (expected)", end, start)
+	}
+}
+
+// A road that goes through both road pairs of TierI. The TierI tile must only count once.
+func TestStructureRegistryLoopingRoad(t *testing.T) {
+	horizontal := Tile{20, [4]Area{AREA_ROAD, AREA_GRASS, AREA_ROAD, AREA_GRASS}, false, false, connectionsToUint16([]Pos{Pos{0, 2}}), Meeple{-1, -1}}
+	vertical := Tile{20, [4]Area{AREA_GRASS, AREA_ROAD, AREA_GRASS, AREA_ROAD}, false, false, connectionsToUint16([]Pos{Pos{1, 3}}), Meeple{-1, -1}}
+	tierI := Tile{32, [4]Area{AREA_ROAD, AREA_ROAD, AREA_ROAD, AREA_ROAD}, false, false, connectionsToUint16([]Pos{Pos{0, 3}, Pos{1, 2}}), Meeple{-1, -1}}
+	game := generateInitialBoardFromTiles(2, tierI, nil)
+
+	moves := []Move{
+		Move{Tile{21, [4]Area{AREA_ROAD, AREA_ROAD, AREA_GRASS, AREA_GRASS}, false, false, connectionsToUint16([]Pos{Pos{0, 1}}), Meeple{SIDE_LEFT, 0}}, Pos{0, -1}},
+		Move{horizontal, Pos{-1, -1}},
+		Move{Tile{21, [4]Area{AREA_GRASS, AREA_ROAD, AREA_ROAD, AREA_GRASS}, false, false, connectionsToUint16([]Pos{Pos{1, 2}}), Meeple{-1, -1}}, Pos{-2, -1}},
+		Move{vertical, Pos{-2, 0}},
+		Move{Tile{21, [4]Area{AREA_GRASS, AREA_GRASS, AREA_ROAD, AREA_ROAD}, false, false, connectionsToUint16([]Pos{Pos{2, 3}}), Meeple{-1, -1}}, Pos{-2, 1}},
+		Move{horizontal, Pos{-1, 1}},
+		Move{Tile{21, [4]Area{AREA_ROAD, AREA_GRASS, AREA_GRASS, AREA_ROAD}, false, false, connectionsToUint16([]Pos{Pos{0, 3}}), Meeple{-1, -1}}, Pos{0, 1}},
+		// Cloisters on both ends of the road
+		Move{Tile{0, [4]Area{AREA_GRASS, AREA_GRASS, AREA_ROAD, AREA_GRASS}, true, false, 0x0, Meeple{-1, -1}}, Pos{-1, 0}},
+	}
+	for _, m := range moves {
+		game.makeMove(m)
+		checkStructures(t, &game)
+	}
+
+	root, _ := game.structureRegistry().structure(Pos{0, 0}, SIDE_LEFT)
+	if other, _ := game.structureRegistry().structure(Pos{0, 0}, SIDE_RIGHT); other != root {
+		t.Fatalf("Both road pairs of TierI should be part of the same road")
+	}
+	// Seven tiles of the loop, TierI and the cloister
+	scores := make([]int, 2)
+	game.updateImmediatePoints(&scores)
+	if scores[0] != 9 {
+		t.Errorf("The open road should have 9 points but has %v", scores[0])
+	}
+
+	game.makeMove(Move{Tile{0, [4]Area{AREA_ROAD, AREA_GRASS, AREA_GRASS, AREA_GRASS}, true, false, 0x0, Meeple{-1, -1}}, Pos{1, 0}})
+	checkStructures(t, &game)
+	if game.players[0].score != 10 || game.players[0].meeples != 6 {
+		t.Errorf("The closed road should give 10 points and the meeple back: %v", game.players[0])
+	}
+
+	for len(game.lastMoves) > 0 {
+		game.reverseLastMove()
+		checkStructures(t, &game)
+	}
+}
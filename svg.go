@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Numbers are rounded, so the output doesn't change with tiny floating point differences.
+func svgNumber(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+func svgPoints(points []point) string {
+	var parts []string
+	for _, p := range points {
+		parts = append(parts, svgNumber(p.x)+","+svgNumber(p.y))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Writes the board as SVG. The same board and options always give the same output.
+func writeSVG(w io.Writer, board map[Pos]Tile, options RenderOptions) error {
+	shapes, width, height := boardShapes(board, options)
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%v\" height=\"%v\" viewBox=\"0 0 %v %v\">\n",
+		svgNumber(width), svgNumber(height), svgNumber(width), svgNumber(height))
+
+	for _, s := range shapes {
+		switch s.kind {
+		case SHAPE_POLYGON:
+			fmt.Fprintf(out, "<polygon points=\"%v\" fill=\"%v\"/>\n", svgPoints(s.points), svgColor(s.color))
+		case SHAPE_OUTLINE:
+			dash := ""
+			if s.dashed {
+				dash = fmt.Sprintf(" stroke-dasharray=\"%v\"", svgNumber(s.size*3))
+			}
+			fmt.Fprintf(out, "<polygon points=\"%v\" fill=\"none\" stroke=\"%v\" stroke-width=\"%v\"%v/>\n",
+				svgPoints(s.points), svgColor(s.color), svgNumber(s.size), dash)
+		case SHAPE_LINE:
+			fmt.Fprintf(out, "<line x1=\"%v\" y1=\"%v\" x2=\"%v\" y2=\"%v\" stroke=\"%v\" stroke-width=\"%v\"/>\n",
+				svgNumber(s.points[0].x), svgNumber(s.points[0].y), svgNumber(s.points[1].x), svgNumber(s.points[1].y), svgColor(s.color), svgNumber(s.size))
+		case SHAPE_CIRCLE:
+			fmt.Fprintf(out, "<circle cx=\"%v\" cy=\"%v\" r=\"%v\" fill=\"%v\"/>\n",
+				svgNumber(s.points[0].x), svgNumber(s.points[0].y), svgNumber(s.size), svgColor(s.color))
+		}
+	}
+
+	fmt.Fprintf(out, "</svg>\n")
+	return out.Flush()
+}
+
+// Writes the board of the game as SVG, with the last placed tile and the open placements marked.
+func writeGameSVG(game GameState, options RenderOptions, path string) error {
+	options.openPlacements = game.openPlacements
+	options.highlight = game.lastPlacedTiles()
+
+	return writeFile(path, func(w io.Writer) error {
+		return writeSVG(w, game.board, options)
+	})
+}
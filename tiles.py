@@ -22,7 +22,7 @@ def get_tiles(shuffled=False):
     # WinA
     tiles.extend([Tile((Area.Grass, Area.Road, Area.Grass, Area.Grass), True, False, (), (-1, -1)) for i in range(2)])
     # WinB
-    tiles.extend([Tile((Area.Grass, Area.Grass, Area.Grass, Area.Grass), False, False, (), (-1, -1)) for i in range(4)])
+    tiles.extend([Tile((Area.Grass, Area.Grass, Area.Grass, Area.Grass), True, False, (), (-1, -1)) for i in range(4)])
     # WinC
     tiles.extend([Tile((Area.City, Area.City, Area.City, Area.City), False, True, ((0,1,2,3),), (-1, -1))])
     # WinD
@@ -52,13 +52,13 @@ def get_tiles(shuffled=False):
     # WinP
     tiles.extend([Tile((Area.City, Area.Road, Area.Road, Area.City), False, False, ((0,3), (1,2)), (-1, -1)) for i in range(3)])
     # WinQ
-    tiles.extend([Tile((Area.City, Area.Grass, Area.Grass, Area.City), False, True, ((0,2,3),), (-1, -1))])
+    tiles.extend([Tile((Area.City, Area.Grass, Area.City, Area.City), False, True, ((0,2,3),), (-1, -1))])
     # WinR
-    tiles.extend([Tile((Area.City, Area.Grass, Area.Grass, Area.City), False, False, ((0,2,3),), (-1, -1)) for i in range(3)])
+    tiles.extend([Tile((Area.City, Area.Grass, Area.City, Area.City), False, False, ((0,2,3),), (-1, -1)) for i in range(3)])
     # WinS
-    tiles.extend([Tile((Area.City, Area.Road, Area.Road, Area.City), False, True, ((0,2,3),), (-1, -1)) for i in range(2)])
+    tiles.extend([Tile((Area.City, Area.Road, Area.City, Area.City), False, True, ((0,2,3),), (-1, -1)) for i in range(2)])
     # WinT
-    tiles.extend([Tile((Area.City, Area.Road, Area.Road, Area.City), False, False, ((0,2,3),), (-1, -1))])
+    tiles.extend([Tile((Area.City, Area.Road, Area.City, Area.City), False, False, ((0,2,3),), (-1, -1))])
     # WinU
     tiles.extend([Tile((Area.Grass, Area.Road, Area.Grass, Area.Road), False, False, ((1,3),), (-1, -1)) for i in range(8)])
     # WinV
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type expectedTileType struct {
+	name        string
+	count       int
+	sides       [4]Area
+	cloister    bool
+	emblem      bool
+	connections uint16
+}
+
+var g_pythonAreas = map[string]Area{"Area.Road": AREA_ROAD, "Area.Grass": AREA_GRASS, "Area.City": AREA_CITY}
+
+// Reads the tiles of get_tiles() in tiles.py, the reference the Go tiles are checked against.
+// Returns the start tile, the base game tiles and the tiles of the Tier expansion.
+func readPythonTiles(t *testing.T) (expectedTileType, []expectedTileType, []expectedTileType) {
+	data, err := os.ReadFile("tiles.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nameRe := regexp.MustCompile(`^\s*# Win(\w+)$`)
+	tileRe := regexp.MustCompile(`Tile\(\((.*?)\), (True|False), (True|False), \((.*?)\), \(-1, -1\)\)(?: for i in range\((\d+)\))?`)
+	groupRe := regexp.MustCompile(`\(([\d,]+)\)`)
+
+	parse := func(name string, m []string) expectedTileType {
+		e := expectedTileType{name: name, count: 1, cloister: m[2] == "True", emblem: m[3] == "True"}
+		areas := strings.Split(m[1], ", ")
+		if len(areas) != 4 {
+			t.Fatalf("Tile %v in tiles.py has %v sides", name, len(areas))
+		}
+		for i, a := range areas {
+			area, ok := g_pythonAreas[a]
+			if !ok {
+				t.Fatalf("Tile %v in tiles.py has the unknown area %v", name, a)
+			}
+			e.sides[i] = area
+		}
+		for _, group := range groupRe.FindAllStringSubmatch(m[4], -1) {
+			var sides []int
+			for _, s := range strings.Split(group[1], ",") {
+				side, _ := strconv.Atoi(s)
+				sides = append(sides, side)
+			}
+			for _, a := range sides {
+				for _, b := range sides {
+					if a != b {
+						e.connections |= 1 << (a*4 + b)
+					}
+				}
+			}
+		}
+		if m[5] != "" {
+			e.count, _ = strconv.Atoi(m[5])
+		}
+		return e
+	}
+
+	var start expectedTileType
+	var base, extra []expectedTileType
+	name := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		if m := nameRe.FindStringSubmatch(line); m != nil {
+			name = m[1]
+			continue
+		}
+		m := tileRe.FindStringSubmatch(line)
+		switch {
+		case m == nil:
+		case strings.Contains(line, "start_tile ="):
+			start = parse("start", m)
+		case strings.HasPrefix(name, "Tier"):
+			extra = append(extra, parse(name, m))
+		default:
+			base = append(base, parse(name, m))
+		}
+	}
+	if start.name == "" || len(base) != 24 || len(extra) != 12 {
+		t.Fatalf("Unexpected tiles in tiles.py: %v base tiles, %v extra tiles, start tile found: %v", len(base), len(extra), start.name != "")
+	}
+	return start, base, extra
+}
+
+func checkTileTypes(t *testing.T, tiles []Tile, expected []expectedTileType, firstId int) {
+
+	counts := map[int]int{}
+	for _, tile := range tiles {
+		counts[tile.id] += 1
+
+		i := tile.id - firstId
+		if i < 0 || i >= len(expected) {
+			t.Errorf("Unexpected tile id %v", tile.id)
+			continue
+		}
+		e := expected[i]
+		if tile.sides != e.sides || tile.cloister != e.cloister || tile.emblem != e.emblem {
+			t.Errorf("Tile %v has wrong properties: %v", e.name, tile)
+		}
+		if tile.connections != e.connections {
+			t.Errorf("Tile %v has wrong connections. %04x != %04x (expected)", e.name, tile.connections, e.connections)
+		}
+		if tile.meeple.playerIndex != -1 {
+			t.Errorf("Tile %v should not have a meeple: %v", e.name, tile.meeple)
+		}
+	}
+
+	for i, e := range expected {
+		if counts[firstId+i] != e.count {
+			t.Errorf("Tile %v has wrong count. %v != %v (expected)", e.name, counts[firstId+i], e.count)
+		}
+	}
+}
+
+func TestBaseTiles(t *testing.T) {
+	startTile, tiles := getTiles()
+	expectedStart, expected, _ := readPythonTiles(t)
+
+	if len(tiles)+1 != 72 {
+		t.Errorf("The base game should have 72 tiles but has %v", len(tiles)+1)
+	}
+
+	checkTileTypes(t, tiles, expected, 0)
+	// The start tile is the fourth D
+	expectedStart.name = expected[3].name
+	if expectedStart.sides != expected[3].sides || expectedStart.connections != expected[3].connections {
+		t.Errorf("The start tile in tiles.py is not a D tile: %v", expectedStart)
+	}
+	checkTileTypes(t, []Tile{startTile}, []expectedTileType{expectedStart}, 3)
+}
+
+func TestExtraTiles(t *testing.T) {
+	_, base, extra := readPythonTiles(t)
+	checkTileTypes(t, getExtraTiles(), extra, len(base))
+}
+
+func TestConnectionsMatchSides(t *testing.T) {
+	startTile, tiles := getTiles()
+	tiles = append(tiles, startTile)
+	tiles = append(tiles, getExtraTiles()...)
+
+	for _, tile := range tiles {
+		for _, c := range g_connectionIndices {
+			if (tile.connections>>(c.x*4))&(1<<c.y) != 0 && tile.sides[c.x] != tile.sides[c.y] {
+				t.Errorf("Tile %v connects side %v and %v of different area types", tile, c.x, c.y)
+			}
+		}
+	}
+}
+
+func TestRotateTile(t *testing.T) {
+	startTile, tiles := getTiles()
+	tiles = append(tiles, startTile)
+	tiles = append(tiles, getExtraTiles()...)
+
+	for _, tile := range tiles {
+		rotated := rotateTile(tile)
+
+		for _, c := range g_connectionIndices {
+			connected := (tile.connections>>(c.x*4))&(1<<c.y) != 0
+			x, y := (c.x+1)%4, (c.y+1)%4
+			rotatedConnected := (rotated.connections>>(x*4))&(1<<y) != 0
+			if connected != rotatedConnected {
+				t.Errorf("Connection %v of tile %v was not rotated correctly: %04x", c, tile, rotated.connections)
+			}
+		}
+
+		for i := 1; i < 4; i++ {
+			rotated = rotateTile(rotated)
+		}
+		if rotated.sides != tile.sides || rotated.connections != tile.connections {
+			t.Errorf("Rotating tile %v four times should not change it. Got %v", tile, rotated)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The on-disk description of a single tile type. Sides are given in the usual order
+// left, down, right, up and use the area names "grass", "city" and "road".
+// Connections are groups of side indices that belong to the same road or city,
+// just like in tiles.py. So [[0, 2, 3]] connects the left, right and upper side.
+type tileTypeFile struct {
+	Id          string   `json:"id"`
+	Sides       []string `json:"sides"`
+	Connections [][]int  `json:"connections,omitempty"`
+	Cloister    bool     `json:"cloister,omitempty"`
+	Emblem      bool     `json:"emblem,omitempty"`
+	Count       int      `json:"count"`
+	// One tile of this type is taken out of the deck and used as the start tile.
+	Start bool `json:"start,omitempty"`
+	// Tiles with an expansion tag are only part of the deck if the expansion is enabled.
+	Expansion string `json:"expansion,omitempty"`
+}
+
+type tileSetFile struct {
+	Name  string         `json:"name"`
+	Tiles []tileTypeFile `json:"tiles"`
+}
+
+// A validated tile set. The index of names and expansions is the tile id.
+type TileSet struct {
+	name       string
+	startTile  Tile
+	types      []Tile
+	counts     []int
+	names      []string
+	expansions []string
+}
+
+var g_areaNames = map[string]Area{"grass": AREA_GRASS, "city": AREA_CITY, "road": AREA_ROAD}
+
+func loadTileSet(path string) (TileSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return TileSet{}, err
+	}
+	return parseTileSet(data)
+}
+
+func parseTileSet(data []byte) (TileSet, error) {
+	var f tileSetFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return TileSet{}, fmt.Errorf("invalid tile set: %v", err)
+	}
+	return f.validate()
+}
+
+// Checks a single connection group and returns its connection pairs.
+func (t tileTypeFile) connectionPairs(sides [4]Area, group []int, used *[4]bool) ([]Pos, error) {
+	if len(group) < 2 {
+		return nil, fmt.Errorf("tile %v: connection group %v needs at least two sides", t.Id, group)
+	}
+	for _, s := range group {
+		if s < 0 || s > 3 {
+			return nil, fmt.Errorf("tile %v: connection group %v has invalid side %v", t.Id, group, s)
+		}
+		if (*used)[s] {
+			return nil, fmt.Errorf("tile %v: side %v is part of more than one connection group", t.Id, s)
+		}
+		(*used)[s] = true
+		if sides[s] == AREA_GRASS {
+			return nil, fmt.Errorf("tile %v: connection group %v touches the grass side %v", t.Id, group, s)
+		}
+		if sides[s] != sides[group[0]] {
+			return nil, fmt.Errorf("tile %v: connection group %v mixes %v and %v", t.Id, group, sides[group[0]], sides[s])
+		}
+	}
+
+	var pairs []Pos
+	for i := 0; i < len(group); i++ {
+		for j := i + 1; j < len(group); j++ {
+			pairs = append(pairs, Pos{group[i], group[j]})
+		}
+	}
+	return pairs, nil
+}
+
+func (t tileTypeFile) tile(id int) (Tile, error) {
+	tile := Tile{id, [4]Area{}, t.Cloister, t.Emblem, 0, Meeple{-1, -1}}
+
+	if len(t.Sides) != 4 {
+		return tile, fmt.Errorf("tile %v: needs exactly 4 sides but has %v", t.Id, len(t.Sides))
+	}
+	for i, s := range t.Sides {
+		area, ok := g_areaNames[s]
+		if !ok {
+			return tile, fmt.Errorf("tile %v: unknown area %q", t.Id, s)
+		}
+		tile.sides[i] = area
+	}
+
+	var used [4]bool
+	var conns []Pos
+	for _, group := range t.Connections {
+		pairs, err := t.connectionPairs(tile.sides, group, &used)
+		if err != nil {
+			return tile, err
+		}
+		conns = append(conns, pairs...)
+	}
+	tile.connections = connectionsToUint16(conns)
+
+	return tile, nil
+}
+
+func (f tileSetFile) validate() (TileSet, error) {
+	ts := TileSet{name: f.Name}
+	ids := map[string]bool{}
+	hasStart := false
+
+	for i, t := range f.Tiles {
+		if t.Id == "" {
+			return ts, fmt.Errorf("tile type %v has no id", i)
+		}
+		if ids[t.Id] {
+			return ts, fmt.Errorf("duplicate tile id %v", t.Id)
+		}
+		ids[t.Id] = true
+
+		tile, err := t.tile(i)
+		if err != nil {
+			return ts, err
+		}
+
+		count := t.Count
+		if t.Start {
+			if hasStart {
+				return ts, fmt.Errorf("tile %v: there is already another start tile", t.Id)
+			}
+			if t.Expansion != "" {
+				return ts, fmt.Errorf("tile %v: the start tile can not be part of an expansion", t.Id)
+			}
+			hasStart = true
+			ts.startTile = tile
+			count -= 1
+		}
+		if count < 0 {
+			return ts, fmt.Errorf("tile %v: invalid count %v", t.Id, t.Count)
+		}
+
+		ts.types = append(ts.types, tile)
+		ts.counts = append(ts.counts, count)
+		ts.names = append(ts.names, t.Id)
+		ts.expansions = append(ts.expansions, t.Expansion)
+	}
+
+	if !hasStart {
+		return ts, fmt.Errorf("tile set %q has no start tile", f.Name)
+	}
+	return ts, nil
+}
+
+// Returns the start tile and the deck in the order of the tile set. Tiles of an expansion are only included
+// if the expansion is listed.
+func (ts TileSet) deck(expansions []string) (Tile, []Tile) {
+	enabled := map[string]bool{"": true}
+	for _, e := range expansions {
+		enabled[e] = true
+	}
+
+	var tiles []Tile
+	for i, t := range ts.types {
+		if enabled[ts.expansions[i]] {
+			multiplyTile(&tiles, t, ts.counts[i])
+		}
+	}
+
+	return ts.startTile, tiles
+}
+
+// Returns the name of the tile type with the given id. Falls back to the id itself.
+func (ts TileSet) tileName(id int) string {
+	if id >= 0 && id < len(ts.names) {
+		return ts.names[id]
+	}
+	return fmt.Sprintf("%v", id)
+}
+
+//go:embed tilesets/base.json
+var g_baseTileSetFile []byte
+
+var g_baseTileSet = mustParseTileSet(g_baseTileSetFile)
+
+func mustParseTileSet(data []byte) TileSet {
+	ts, err := parseTileSet(data)
+	if err != nil {
+		panic(err)
+	}
+	return ts
+}
+
+// The built-in tile set of tilesets/base.json, which getTiles() and getExtraTiles() are taken from.
+// The base tiles are named A - X, the extra tiles TierA - TierL and are part of the expansion "tier".
+func baseTileSet() TileSet {
+	return g_baseTileSet
+}
+
+// Returns the connected sides of the tile, in the format of the tile set files.
+func (t Tile) connectionGroups() (groups [][]int) {
+	var grouped [4]bool
+	for a := 0; a < 4; a++ {
+		if grouped[a] {
+			continue
+		}
+		group := []int{a}
+		for b := a + 1; b < 4; b++ {
+			if t.isConnected(a, b) {
+				group = append(group, b)
+				grouped[b] = true
+			}
+		}
+		if len(group) > 1 {
+			groups = append(groups, group)
+		}
+	}
+	return
+}
+
+// The on-disk description of the tile set. parseTileSet() of it returns the same tile set again.
+func (ts TileSet) file() tileSetFile {
+	f := tileSetFile{Name: ts.name}
+	for i, t := range ts.types {
+		sides := make([]string, 4, 4)
+		for s, area := range t.sides {
+			sides[s] = strings.ToLower(area.String())
+		}
+		typeFile := tileTypeFile{ts.names[i], sides, t.connectionGroups(), t.cloister, t.emblem, ts.counts[i], false, ts.expansions[i]}
+		if i == ts.startTile.id {
+			typeFile.Start = true
+			typeFile.Count += 1
+		}
+		f.Tiles = append(f.Tiles, typeFile)
+	}
+	return f
+}
+
+// Returns the id of the tile type with the given name.
+func (ts TileSet) tileId(name string) (int, bool) {
+	for i, n := range ts.names {
+		if n == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// Returns how many tiles of every tile id there are.
+func countTileIds(tiles []Tile) map[int]int {
+	counts := map[int]int{}
+	for _, t := range tiles {
+		counts[t.id] += 1
+	}
+	return counts
+}
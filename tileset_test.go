@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadBaseTileSet(t *testing.T) {
+	tileSet, err := loadTileSet("tilesets/base.json")
+	if err != nil {
+		t.Fatalf("Loading the base tile set failed: %v", err)
+	}
+
+	startTile, tiles := tileSet.deck(nil)
+	expectedStart, expectedTiles := getTiles()
+	_, expectedBase, _ := readPythonTiles(t)
+
+	if startTile != expectedStart {
+		t.Errorf("Wrong start tile. %v != %v (expected)", startTile, expectedStart)
+	}
+	checkTileTypes(t, tiles, expectedBase, 0)
+	if len(tiles) != len(expectedTiles) {
+		t.Errorf("Wrong deck size. %v != %v (expected)", len(tiles), len(expectedTiles))
+	}
+
+	_, tiles = tileSet.deck([]string{"tier"})
+	extraCounts := countTileIds(getExtraTiles())
+	counts := countTileIds(tiles)
+	for id, count := range extraCounts {
+		if counts[id] != count {
+			t.Errorf("Tile %v has wrong count with the expansion. %v != %v (expected)", tileSet.tileName(id), counts[id], count)
+		}
+	}
+	if len(tiles) != len(expectedTiles)+len(getExtraTiles()) {
+		t.Errorf("Wrong deck size with the expansion: %v", len(tiles))
+	}
+
+	if tileSet.tileName(3) != "D" || tileSet.tileName(24) != "TierA" {
+		t.Errorf("Wrong tile names: %v, %v", tileSet.tileName(3), tileSet.tileName(24))
+	}
+
+	if !reflect.DeepEqual(tileSet, baseTileSet()) {
+		t.Errorf("The base tile set file differs from the built-in tiles")
+	}
+	data, err := json.Marshal(tileSet.file())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed, err := parseTileSet(data); err != nil || !reflect.DeepEqual(parsed, tileSet) {
+		t.Errorf("The tile set changed after writing and parsing it again (%v)", err)
+	}
+}
+
+func TestInvalidTileSets(t *testing.T) {
+	cases := []struct {
+		name  string
+		data  string
+		error string
+	}{
+		{"mixed group", `{"tiles": [{"id": "A", "sides": ["road", "grass", "city", "grass"], "connections": [[0, 2]], "count": 1, "start": true}]}`, "mixes"},
+		{"grass group", `{"tiles": [{"id": "A", "sides": ["grass", "grass", "road", "road"], "connections": [[0, 1]], "count": 1, "start": true}]}`, "grass"},
+		{"duplicate id", `{"tiles": [{"id": "A", "sides": ["road", "grass", "road", "grass"], "count": 1, "start": true}, {"id": "A", "sides": ["road", "grass", "road", "grass"], "count": 1}]}`, "duplicate"},
+		{"missing start", `{"tiles": [{"id": "A", "sides": ["road", "grass", "road", "grass"], "count": 1}]}`, "start tile"},
+		{"two starts", `{"tiles": [{"id": "A", "sides": ["road", "grass", "road", "grass"], "count": 1, "start": true}, {"id": "B", "sides": ["road", "grass", "road", "grass"], "count": 1, "start": true}]}`, "start tile"},
+		{"unknown area", `{"tiles": [{"id": "A", "sides": ["river", "grass", "road", "grass"], "count": 1, "start": true}]}`, "unknown area"},
+		{"three sides", `{"tiles": [{"id": "A", "sides": ["road", "grass", "road"], "count": 1, "start": true}]}`, "4 sides"},
+		{"invalid side", `{"tiles": [{"id": "A", "sides": ["road", "grass", "road", "grass"], "connections": [[0, 4]], "count": 1, "start": true}]}`, "invalid side"},
+		{"overlapping groups", `{"tiles": [{"id": "A", "sides": ["road", "road", "road", "grass"], "connections": [[0, 1], [1, 2]], "count": 1, "start": true}]}`, "more than one"},
+		{"negative count", `{"tiles": [{"id": "A", "sides": ["road", "grass", "road", "grass"], "count": 0, "start": true}]}`, "count"},
+		{"no json", `tiles: []`, "invalid tile set"},
+	}
+
+	for _, c := range cases {
+		_, err := parseTileSet([]byte(c.data))
+		if err == nil {
+			t.Errorf("%v: the tile set should be rejected", c.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.error) {
+			t.Errorf("%v: unexpected error %q", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// A tournament plays every combination of tableSize agents against each other. Every table plays the same
+// decks: game g uses the seed seed + g/tableSize and the seats rotated by g%tableSize, so every agent gets to
+// play every deck from every seat, if games is a multiple of tableSize. Agents are created anew for every
+// game, so games can run in parallel.
+type TournamentConfig struct {
+	// Names of the agents as given to newAgent(). The same agent may be listed more than once
+	agents []string
+	// Players per game
+	tableSize int
+	// Games per table
+	games      int
+	seed       int64
+	parallel   int
+	tileSet    TileSet
+	expansions []string
+}
+
+func defaultTournamentConfig(agents []string) TournamentConfig {
+	return TournamentConfig{agents, 2, 10, 1, runtime.NumCPU(), baseTileSet(), nil}
+}
+
+// The result of a single game. Seats holds the index of the agent of each player.
+type tournamentGame struct {
+	Table  int   `json:"table"`
+	Seed   int64 `json:"seed"`
+	Seats  []int `json:"seats"`
+	Scores []int `json:"scores"`
+	// Meeples of each player that were not on the board when the deck was empty
+	Meeples []int `json:"meeples"`
+	Winners []int `json:"winners"`
+}
+
+type agentStats struct {
+	Agent string `json:"agent"`
+	Games int    `json:"games"`
+	// A win shared by several players counts as a fraction of a win
+	Wins    float64 `json:"wins"`
+	WinRate float64 `json:"winRate"`
+	// 95% confidence intervals: Wilson score interval for the win rate, normal approximation for the score
+	WinRateLow    float64 `json:"winRateLow"`
+	WinRateHigh   float64 `json:"winRateHigh"`
+	MeanScore     float64 `json:"meanScore"`
+	ScoreVariance float64 `json:"scoreVariance"`
+	ScoreLow      float64 `json:"scoreLow"`
+	ScoreHigh     float64 `json:"scoreHigh"`
+	MeanMeeples   float64 `json:"meanMeeples"`
+}
+
+type tableStats struct {
+	Agents []string     `json:"agents"`
+	Stats  []agentStats `json:"stats"`
+}
+
+type tournamentReport struct {
+	Seed      int64            `json:"seed"`
+	TableSize int              `json:"tableSize"`
+	Games     int              `json:"gamesPerTable"`
+	Agents    []agentStats     `json:"agents"`
+	Tables    []tableStats     `json:"tables"`
+	Results   []tournamentGame `json:"results"`
+}
+
+// z of the two sided 95% confidence interval
+const CONFIDENCE_Z = 1.96
+
+// Returns all combinations of size agents out of count, in lexicographic order.
+func tournamentTables(count, size int) (tables [][]int) {
+	var combine func(table []int, next int)
+	combine = func(table []int, next int) {
+		if len(table) == size {
+			tables = append(tables, append([]int(nil), table...))
+			return
+		}
+		for i := next; i <= count-size+len(table); i++ {
+			combine(append(table, i), i+1)
+		}
+	}
+	combine(nil, 0)
+	return
+}
+
+// Unique labels for the agents. Agents listed more than once are numbered.
+func (config TournamentConfig) agentLabels() []string {
+	counts := map[string]int{}
+	for _, name := range config.agents {
+		counts[name] += 1
+	}
+	seen := map[string]int{}
+	labels := make([]string, len(config.agents))
+	for i, name := range config.agents {
+		labels[i] = name
+		if counts[name] > 1 {
+			seen[name] += 1
+			labels[i] = fmt.Sprintf("%v#%v", name, seen[name])
+		}
+	}
+	return labels
+}
+
+func (config TournamentConfig) validate() error {
+	if config.tableSize < 1 || config.tableSize > MAX_PLAYERS {
+		return fmt.Errorf("the table size must be between 1 and %v", MAX_PLAYERS)
+	}
+	if len(config.agents) < config.tableSize {
+		return fmt.Errorf("%v agents are not enough for tables of %v", len(config.agents), config.tableSize)
+	}
+	if config.games < 1 || config.parallel < 1 {
+		return fmt.Errorf("the number of games and parallel games must be positive")
+	}
+	for _, name := range config.agents {
+		if unlabeledAgentName(name) == "human" {
+			return fmt.Errorf("humans can't play in a tournament")
+		}
+		if _, err := newAgent(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Plays all games of the tournament. The results are in the order of the tables and games, no matter which game finished first.
+func runTournament(config TournamentConfig) ([]tournamentGame, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	var results []tournamentGame
+	for t, table := range tournamentTables(len(config.agents), config.tableSize) {
+		for g := 0; g < config.games; g++ {
+			seats := make([]int, config.tableSize)
+			for i := range seats {
+				seats[i] = table[(i+g)%config.tableSize]
+			}
+			results = append(results, tournamentGame{Table: t, Seed: config.seed + int64(g/config.tableSize), Seats: seats})
+		}
+	}
+
+	jobs := make(chan *tournamentGame)
+	var wg sync.WaitGroup
+	for i := 0; i < config.parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for result := range jobs {
+				config.playGame(result)
+			}
+		}()
+	}
+	for i := range results {
+		jobs <- &results[i]
+	}
+	close(jobs)
+	wg.Wait()
+	return results, nil
+}
+
+// Plays the game of the result with its seats and seed and fills in the rest.
+func (config TournamentConfig) playGame(result *tournamentGame) {
+	var agents []Agent
+	for _, seat := range result.Seats {
+		// The names were checked by validate()
+		agent, _ := newAgent(config.agents[seat])
+		agents = append(agents, agent)
+	}
+
+	game := generateTileSetBoard(len(agents), config.tileSet, config.expansions, result.Seed)
+	runGame(&game, agents)
+
+	// The final scoring returns all meeples, so those returned by it were still on the board
+	final := game.lastMoves[len(game.lastMoves)-1]
+	result.Meeples = make([]int, len(game.players))
+	result.Scores = make([]int, len(game.players))
+	for i, p := range game.players {
+		result.Scores[i] = p.score
+		result.Meeples[i] = p.meeples
+	}
+	for _, r := range final.playerToBoardMeeple {
+		result.Meeples[r.playerIndex] -= 1
+	}
+	result.Winners = game.winners()
+}
+
+// Returns the 95% Wilson score interval of the rate of wins in games.
+func wilsonInterval(wins float64, games int) (float64, float64) {
+	if games == 0 {
+		return 0, 1
+	}
+	n := float64(games)
+	p := wins / n
+	z2 := CONFIDENCE_Z * CONFIDENCE_Z
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	spread := CONFIDENCE_Z / (1 + z2/n) * math.Sqrt(p*(1-p)/n+z2/(4*n*n))
+	return math.Max(0, center-spread), math.Min(1, center+spread)
+}
+
+// Returns the statistics of every agent over the given games, in the order of the agents.
+func (config TournamentConfig) agentStats(results []tournamentGame, agents []int) []agentStats {
+	labels := config.agentLabels()
+	var stats []agentStats
+	for _, agent := range agents {
+		s := agentStats{Agent: labels[agent]}
+		var scores []float64
+		meeples := 0
+		for _, r := range results {
+			for player, seat := range r.Seats {
+				if seat != agent {
+					continue
+				}
+				scores = append(scores, float64(r.Scores[player]))
+				meeples += r.Meeples[player]
+				for _, w := range r.Winners {
+					if w == player {
+						s.Wins += 1 / float64(len(r.Winners))
+					}
+				}
+			}
+		}
+
+		s.Games = len(scores)
+		if s.Games > 0 {
+			n := float64(s.Games)
+			for _, score := range scores {
+				s.MeanScore += score
+			}
+			s.MeanScore /= n
+			// The sample variance, so it is an unbiased estimate
+			if s.Games > 1 {
+				for _, score := range scores {
+					s.ScoreVariance += (score - s.MeanScore) * (score - s.MeanScore)
+				}
+				s.ScoreVariance /= n - 1
+			}
+			spread := CONFIDENCE_Z * math.Sqrt(s.ScoreVariance/n)
+			s.ScoreLow, s.ScoreHigh = s.MeanScore-spread, s.MeanScore+spread
+			s.WinRate = s.Wins / n
+			s.MeanMeeples = float64(meeples) / n
+		}
+		s.WinRateLow, s.WinRateHigh = wilsonInterval(s.Wins, s.Games)
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+func (config TournamentConfig) report(results []tournamentGame) tournamentReport {
+	report := tournamentReport{Seed: config.seed, TableSize: config.tableSize, Games: config.games, Results: results}
+	var all []int
+	for i := range config.agents {
+		all = append(all, i)
+	}
+	report.Agents = config.agentStats(results, all)
+	// Best agents first
+	sort.SliceStable(report.Agents, func(i, j int) bool {
+		return report.Agents[i].WinRate > report.Agents[j].WinRate
+	})
+
+	labels := config.agentLabels()
+	for t, table := range tournamentTables(len(config.agents), config.tableSize) {
+		var games []tournamentGame
+		for _, r := range results {
+			if r.Table == t {
+				games = append(games, r)
+			}
+		}
+		ts := tableStats{Stats: config.agentStats(games, table)}
+		for _, agent := range table {
+			ts.Agents = append(ts.Agents, labels[agent])
+		}
+		report.Tables = append(report.Tables, ts)
+	}
+	return report
+}
+
+func writeStatsTable(w *tabwriter.Writer, stats []agentStats) {
+	fmt.Fprintln(w, "Agent\tGames\tWins\tWin rate\t95% CI\tMean score\tVariance\t95% CI\tMeeples left\t")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%v\t%v\t%.1f\t%.3f\t%.3f - %.3f\t%.1f\t%.1f\t%.1f - %.1f\t%.2f\t\n",
+			s.Agent, s.Games, s.Wins, s.WinRate, s.WinRateLow, s.WinRateHigh, s.MeanScore, s.ScoreVariance, s.ScoreLow, s.ScoreHigh, s.MeanMeeples)
+	}
+}
+
+// Writes the statistics of all agents and of every table as text tables.
+func (report tournamentReport) writeText(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "%v games per table of %v, seed %v\n\n", report.Games, report.TableSize, report.Seed)
+	writeStatsTable(w, report.Agents)
+	for _, table := range report.Tables {
+		fmt.Fprintf(w, "\nTable %v\n", table.Agents)
+		writeStatsTable(w, table.Stats)
+	}
+	return w.Flush()
+}
+
+// Writes one row per agent and table. Tables are named like "greedy vs mcts", the statistics over all tables have the table "all".
+func (report tournamentReport) writeCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"table", "agent", "games", "wins", "win_rate", "win_rate_low", "win_rate_high",
+		"mean_score", "score_variance", "score_low", "score_high", "mean_meeples"})
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	write := func(table string, stats []agentStats) {
+		for _, s := range stats {
+			w.Write([]string{table, s.Agent, strconv.Itoa(s.Games), f(s.Wins), f(s.WinRate), f(s.WinRateLow), f(s.WinRateHigh),
+				f(s.MeanScore), f(s.ScoreVariance), f(s.ScoreLow), f(s.ScoreHigh), f(s.MeanMeeples)})
+		}
+	}
+	write("all", report.Agents)
+	for _, table := range report.Tables {
+		write(strings.Join(table.Agents, " vs "), table.Stats)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Writes the whole report including the result of every game as JSON.
+func (report tournamentReport) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
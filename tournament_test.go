@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTournamentTables(t *testing.T) {
+	if tables := fmt.Sprint(tournamentTables(4, 2)); tables != "[[0 1] [0 2] [0 3] [1 2] [1 3] [2 3]]" {
+		t.Errorf("Unexpected pairings %v", tables)
+	}
+	if tables := fmt.Sprint(tournamentTables(4, 3)); tables != "[[0 1 2] [0 1 3] [0 2 3] [1 2 3]]" {
+		t.Errorf("Unexpected tables %v", tables)
+	}
+	if tables := tournamentTables(3, 3); len(tables) != 1 {
+		t.Errorf("Expected a single table, got %v", tables)
+	}
+}
+
+func TestWilsonInterval(t *testing.T) {
+	tests := []struct {
+		wins      float64
+		games     int
+		low, high float64
+	}{
+		{0, 10, 0, 0.2775},
+		{5, 10, 0.2366, 0.7634},
+		{10, 10, 0.7225, 1},
+		{0, 0, 0, 1},
+	}
+	for _, test := range tests {
+		low, high := wilsonInterval(test.wins, test.games)
+		if math.Abs(low-test.low) > 1e-4 || math.Abs(high-test.high) > 1e-4 {
+			t.Errorf("%v of %v: expected %v - %v, got %v - %v", test.wins, test.games, test.low, test.high, low, high)
+		}
+	}
+}
+
+func TestTournament(t *testing.T) {
+	config := defaultTournamentConfig([]string{"random", "first", "greedy"})
+	config.games = 4
+	config.seed = 5
+	config.parallel = 1
+	results, err := runTournament(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3*4 {
+		t.Fatalf("Expected 12 games, got %v", len(results))
+	}
+
+	// Seats rotate and every deck is played from both seats
+	for _, r := range results[:4] {
+		if fmt.Sprint(r.Table, r.Seats) != fmt.Sprint(0, []int{0, 1}) && fmt.Sprint(r.Table, r.Seats) != fmt.Sprint(0, []int{1, 0}) {
+			t.Errorf("Unexpected seats %v at table %v", r.Seats, r.Table)
+		}
+	}
+	if seeds := fmt.Sprint(results[0].Seed, results[1].Seed, results[2].Seed, results[3].Seed); seeds != "5 5 6 6" {
+		t.Errorf("Unexpected seeds %v", seeds)
+	}
+	if results[0].Seats[0] != results[1].Seats[1] {
+		t.Errorf("The seats should be rotated: %v %v", results[0].Seats, results[1].Seats)
+	}
+
+	// A single game of the tournament can be played again
+	game := generateInitialBoard(2, results[5].Seed)
+	var agents []Agent
+	for _, seat := range results[5].Seats {
+		agent, _ := newAgent(config.agents[seat])
+		agents = append(agents, agent)
+	}
+	runGame(&game, agents)
+	if scores := fmt.Sprint(game.players[0].score, game.players[1].score); scores != fmt.Sprint(results[5].Scores[0], results[5].Scores[1]) {
+		t.Errorf("Expected the scores %v, got %v", results[5].Scores, scores)
+	}
+
+	// The same results, no matter how many games run at the same time
+	config.parallel = 4
+	parallel, _ := runTournament(config)
+	if !reflect.DeepEqual(results, parallel) {
+		t.Errorf("Parallel games should give the same results")
+	}
+
+	report := config.report(results)
+	if len(report.Tables) != 3 || len(report.Agents) != 3 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	games, wins := 0, 0.0
+	for _, s := range report.Agents {
+		games += s.Games
+		wins += s.Wins
+		if s.Games != 8 || s.WinRateLow > s.WinRate || s.WinRate > s.WinRateHigh || s.ScoreLow > s.MeanScore || s.MeanScore > s.ScoreHigh {
+			t.Errorf("Inconsistent statistics %+v", s)
+		}
+	}
+	if games != 24 || math.Abs(wins-12) > 1e-9 {
+		t.Errorf("Expected 24 seats and 12 wins, got %v and %v", games, wins)
+	}
+	if report.Agents[0].Agent != "greedy" {
+		t.Errorf("Expected greedy to win the tournament, got %v", report.Agents[0].Agent)
+	}
+}
+
+func TestTournamentStats(t *testing.T) {
+	config := defaultTournamentConfig([]string{"a", "b"})
+	results := []tournamentGame{
+		{Seats: []int{0, 1}, Scores: []int{10, 5}, Meeples: []int{1, 2}, Winners: []int{0}},
+		{Seats: []int{1, 0}, Scores: []int{7, 7}, Meeples: []int{3, 0}, Winners: []int{0, 1}},
+		{Seats: []int{0, 1}, Scores: []int{4, 8}, Meeples: []int{2, 2}, Winners: []int{1}},
+	}
+	stats := config.agentStats(results, []int{0, 1})
+	a := stats[0]
+	if a.Games != 3 || a.Wins != 1.5 || a.WinRate != 0.5 || a.MeanScore != 7 || a.ScoreVariance != 9 || a.MeanMeeples != 1 {
+		t.Errorf("Unexpected statistics %+v", a)
+	}
+	if spread := 1.96 * math.Sqrt(3); math.Abs(a.ScoreHigh-7-spread) > 1e-9 || math.Abs(7-a.ScoreLow-spread) > 1e-9 {
+		t.Errorf("Unexpected confidence interval %v - %v", a.ScoreLow, a.ScoreHigh)
+	}
+	if b := stats[1]; b.Wins != 1.5 || b.MeanScore != 20.0/3 {
+		t.Errorf("Unexpected statistics %+v", b)
+	}
+
+	if labels := fmt.Sprint(defaultTournamentConfig([]string{"greedy", "mcts", "greedy"}).agentLabels()); labels != "[greedy#1 mcts greedy#2]" {
+		t.Errorf("Unexpected labels %v", labels)
+	}
+}
+
+func TestTournamentErrors(t *testing.T) {
+	configs := map[string]TournamentConfig{
+		"human":     defaultTournamentConfig([]string{"greedy", "human"}),
+		"labeled":   defaultTournamentConfig([]string{"greedy", "human@me"}),
+		"unknown":   defaultTournamentConfig([]string{"greedy", "nobody"}),
+		"too few":   defaultTournamentConfig([]string{"greedy"}),
+		"no games":  defaultTournamentConfig([]string{"greedy", "first"}),
+		"big table": defaultTournamentConfig([]string{"greedy", "first"}),
+	}
+	c := configs["no games"]
+	c.games = 0
+	configs["no games"] = c
+	c = configs["big table"]
+	c.tableSize = MAX_PLAYERS + 1
+	configs["big table"] = c
+	for name, config := range configs {
+		if _, err := runTournament(config); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+func TestTournamentOutput(t *testing.T) {
+	config := defaultTournamentConfig([]string{"first", "greedy"})
+	config.games = 2
+	results, _ := runTournament(config)
+	report := config.report(results)
+
+	var out bytes.Buffer
+	if err := report.writeCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1+2+2 || rows[0][0] != "table" || rows[1][0] != "all" || rows[3][0] != "first vs greedy" {
+		t.Errorf("Unexpected CSV %v", rows)
+	}
+
+	out.Reset()
+	report.writeJSON(&out)
+	var decoded tournamentReport
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, report) {
+		t.Errorf("The JSON report should decode to the same report")
+	}
+
+	out.Reset()
+	report.writeText(&out)
+	if !strings.Contains(out.String(), "Table [first greedy]") {
+		t.Errorf("Unexpected text report:\n%v", out.String())
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// A minimal WebSocket (RFC 6455) implementation, just enough to push events to the clients of the server.
+// Fragmented messages, ping/pong and the closing handshake are supported, extensions are not.
+const (
+	WS_OPCODE_CONTINUATION = 0x0
+	WS_OPCODE_TEXT         = 0x1
+	WS_OPCODE_BINARY       = 0x2
+	WS_OPCODE_CLOSE        = 0x8
+	WS_OPCODE_PING         = 0x9
+	WS_OPCODE_PONG         = 0xa
+)
+
+const (
+	WS_CLOSE_NORMAL         = 1000
+	WS_CLOSE_GOING_AWAY     = 1001
+	WS_CLOSE_PROTOCOL_ERROR = 1002
+	WS_CLOSE_TOO_BIG        = 1009
+	WS_CLOSE_INTERNAL_ERROR = 1011
+)
+
+// Longer messages from a client close the connection. The clients only ever need to send control frames.
+const WS_MAX_MESSAGE = 1 << 16
+
+// The key of the client is combined with this to prove that the server understands WebSockets.
+const WS_GUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var errWebSocketClosed = errors.New("websocket closed")
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	// Clients mask their frames, servers don't
+	client bool
+	// Control frames are answered while reading, so writes can come from two goroutines
+	writeMutex sync.Mutex
+	closed     bool
+}
+
+func webSocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + WS_GUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// Returns true if the comma separated header contains the token, ignoring case.
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, value := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Does the opening handshake and takes over the connection of the request.
+// If the request isn't a valid WebSocket handshake, an error response is written.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	switch {
+	case r.Method != http.MethodGet:
+		err = fmt.Errorf("websocket handshake needs GET, not %v", r.Method)
+	case !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket"):
+		err = fmt.Errorf("not a websocket handshake")
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		err = fmt.Errorf("unsupported websocket version %q", r.Header.Get("Sec-WebSocket-Version"))
+	case err != nil || len(decoded) != 16:
+		err = fmt.Errorf("invalid websocket key %q", key)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return nil, err
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "websockets are not supported")
+		return nil, fmt.Errorf("the response writer can't be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %v\r\n\r\n", webSocketAccept(key))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if c.closed {
+		return errWebSocketClosed
+	}
+
+	header := []byte{0x80 | opcode, 0}
+	switch length := len(payload); {
+	case length < 126:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header[1] = 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	if c.client {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		header[1] |= 0x80
+		header = append(header, key[:]...)
+		masked := make([]byte, len(payload))
+		for i, b := range payload {
+			masked[i] = b ^ key[i%4]
+		}
+		payload = masked
+	}
+
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+func (c *wsConn) writeText(data []byte) error {
+	return c.writeFrame(WS_OPCODE_TEXT, data)
+}
+
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.rw, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	if header[0]&0x70 != 0 {
+		return fin, opcode, nil, fmt.Errorf("websocket extensions are not supported")
+	}
+	if masked := header[1]&0x80 != 0; masked == c.client {
+		return fin, opcode, nil, fmt.Errorf("client frames must be masked and server frames must not")
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err = io.ReadFull(c.rw, extended[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err = io.ReadFull(c.rw, extended[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > WS_MAX_MESSAGE {
+		return fin, opcode, nil, fmt.Errorf("websocket frame of %v bytes is too big", length)
+	}
+	if opcode >= WS_OPCODE_CLOSE && (!fin || length > 125) {
+		return fin, opcode, nil, fmt.Errorf("invalid control frame")
+	}
+
+	var key [4]byte
+	if !c.client {
+		if _, err = io.ReadFull(c.rw, key[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.rw, payload); err != nil {
+		return
+	}
+	if !c.client {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+	return
+}
+
+// Reads the next text or binary message. Pings are answered on the way. Returns errWebSocketClosed
+// after the closing handshake. On protocol errors the connection is closed.
+func (c *wsConn) readMessage() (opcode byte, message []byte, err error) {
+	for {
+		fin, frameOpcode, payload, err := c.readFrame()
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				c.close(WS_CLOSE_PROTOCOL_ERROR, err.Error())
+			}
+			return 0, nil, err
+		}
+
+		switch frameOpcode {
+		case WS_OPCODE_PING:
+			if err := c.writeFrame(WS_OPCODE_PONG, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case WS_OPCODE_PONG:
+			continue
+		case WS_OPCODE_CLOSE:
+			// Answers with the same status code
+			code := WS_CLOSE_NORMAL
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			c.close(code, "")
+			return 0, nil, errWebSocketClosed
+		case WS_OPCODE_TEXT, WS_OPCODE_BINARY:
+			if opcode != 0 {
+				c.close(WS_CLOSE_PROTOCOL_ERROR, "expected a continuation frame")
+				return 0, nil, fmt.Errorf("expected a continuation frame")
+			}
+			opcode = frameOpcode
+		case WS_OPCODE_CONTINUATION:
+			if opcode == 0 {
+				c.close(WS_CLOSE_PROTOCOL_ERROR, "unexpected continuation frame")
+				return 0, nil, fmt.Errorf("unexpected continuation frame")
+			}
+		default:
+			c.close(WS_CLOSE_PROTOCOL_ERROR, "unknown opcode")
+			return 0, nil, fmt.Errorf("unknown opcode %v", frameOpcode)
+		}
+
+		message = append(message, payload...)
+		if len(message) > WS_MAX_MESSAGE {
+			c.close(WS_CLOSE_TOO_BIG, "message too big")
+			return 0, nil, fmt.Errorf("websocket message of %v bytes is too big", len(message))
+		}
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+// Sends a close frame and closes the connection. Closing twice does nothing.
+func (c *wsConn) close(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	// Close frames are limited to 125 bytes like all control frames
+	if len(payload) > 125 {
+		payload = payload[:125]
+	}
+	err := c.writeFrame(WS_OPCODE_CLOSE, payload)
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.conn.Close()
+	return err
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"testing"
+	"time"
+)
+
+// Connects to the WebSocket at the http:// url, just like a browser would.
+func dialWebSocket(t *testing.T, url string) *wsConn {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	fmt.Fprintf(conn, "GET %v HTTP/1.1\r\nHost: %v\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\nSec-WebSocket-Key: %v\r\nSec-WebSocket-Version: 13\r\n\r\n", u.Path, u.Host, key)
+
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		t.Fatalf("The handshake failed: %v %v", res.Status, res.Header)
+	}
+	return &wsConn{conn: conn, rw: bufio.NewReadWriter(r, bufio.NewWriter(conn)), client: true}
+}
+
+// A connected server and client, without any HTTP in between.
+func webSocketPipe() (server, client *wsConn) {
+	a, b := net.Pipe()
+	server = &wsConn{conn: a, rw: bufio.NewReadWriter(bufio.NewReader(a), bufio.NewWriter(a))}
+	client = &wsConn{conn: b, rw: bufio.NewReadWriter(bufio.NewReader(b), bufio.NewWriter(b)), client: true}
+	return
+}
+
+func TestWebSocketMessages(t *testing.T) {
+	server, client := webSocketPipe()
+
+	// The three different length encodings
+	for _, length := range []int{0, 125, 126, 1000, WS_MAX_MESSAGE} {
+		message := bytes.Repeat([]byte{'x'}, length)
+		go client.writeText(message)
+		opcode, received, err := server.readMessage()
+		if err != nil || opcode != WS_OPCODE_TEXT || !bytes.Equal(received, message) {
+			t.Errorf("Expected a text message of %v bytes, got %v bytes with opcode %v (%v)", length, len(received), opcode, err)
+		}
+	}
+
+	// Back to the client, which doesn't mask
+	go server.writeText([]byte("hello"))
+	if _, received, err := client.readMessage(); err != nil || string(received) != "hello" {
+		t.Errorf("Expected hello, got %q (%v)", received, err)
+	}
+
+	// A fragmented message with a ping in between
+	go func() {
+		// writeFrame() always sets fin
+		client.writeMutex.Lock()
+		client.rw.Write([]byte{WS_OPCODE_TEXT, 0x80 | 3, 0, 0, 0, 0})
+		client.rw.WriteString("abc")
+		client.rw.Flush()
+		client.writeMutex.Unlock()
+		client.writeFrame(WS_OPCODE_PING, []byte("ping"))
+		client.writeFrame(WS_OPCODE_CONTINUATION, []byte("def"))
+	}()
+	done := make(chan bool)
+	go func() {
+		if _, received, err := server.readMessage(); err != nil || string(received) != "abcdef" {
+			t.Errorf("Expected abcdef, got %q (%v)", received, err)
+		}
+		close(done)
+	}()
+	if opcode, payload, err := readControlFrame(client); err != nil || opcode != WS_OPCODE_PONG || string(payload) != "ping" {
+		t.Errorf("Expected a pong, got %v %q (%v)", opcode, payload, err)
+	}
+	<-done
+
+	// Closing is answered with the same code
+	go client.close(WS_CLOSE_GOING_AWAY, "bye")
+	if _, _, err := server.readMessage(); err != errWebSocketClosed {
+		t.Errorf("Expected the connection to be closed, got %v", err)
+	}
+}
+
+func readControlFrame(c *wsConn) (byte, []byte, error) {
+	_, opcode, payload, err := c.readFrame()
+	return opcode, payload, err
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	frames := map[string][]byte{
+		"unmasked":     {0x80 | WS_OPCODE_TEXT, 1, 'x'},
+		"extension":    {0xc0 | WS_OPCODE_TEXT, 0x80, 0, 0, 0, 0},
+		"too big":      {0x80 | WS_OPCODE_TEXT, 0x80 | 127, 0, 0, 0, 0, 0, 2, 0, 0},
+		"continuation": {0x80 | WS_OPCODE_CONTINUATION, 0x80, 0, 0, 0, 0},
+		"opcode":       {0x80 | 0x3, 0x80, 0, 0, 0, 0},
+		"long ping":    {0x80 | WS_OPCODE_PING, 0x80 | 126, 0, 126, 0, 0, 0, 0},
+	}
+	for name, frame := range frames {
+		server, client := webSocketPipe()
+		go func() {
+			client.conn.Write(frame)
+			// The server answers with a close frame
+			client.readFrame()
+		}()
+		if _, _, err := server.readMessage(); err == nil || err == errWebSocketClosed {
+			t.Errorf("%v: expected a protocol error, got %v", name, err)
+		}
+	}
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	server := httptest.NewServer(newGameServer())
+	defer server.Close()
+	state := createTestGame(t, server.URL, 2, 3)
+
+	// Without the upgrade headers
+	var e errorResponse
+	if status := request(t, "GET", server.URL+"/games/"+state.Id+"/events", nil, &e); status != http.StatusBadRequest {
+		t.Errorf("Expected a bad request, got %v", status)
+	}
+	if status := request(t, "POST", server.URL+"/games/"+state.Id+"/events", nil, &e); status != http.StatusMethodNotAllowed {
+		t.Errorf("Expected method not allowed, got %v", status)
+	}
+}
+
+// Reads the next event and decodes it into the event type given by its type.
+func readEvent(t *testing.T, c *wsConn) interface{} {
+	_, data, err := c.readMessage()
+	if err != nil {
+		t.Fatalf("Reading the next event failed: %v", err)
+	}
+	var header struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(data, &header)
+
+	events := map[string]interface{}{
+		EVENT_STATE:           &stateEvent{},
+		EVENT_MOVE:            &moveEvent{},
+		EVENT_SCORE:           &scoreEvent{},
+		EVENT_MEEPLE_RETURNED: &meepleReturnedEvent{},
+		EVENT_TURN:            &turnEvent{},
+		EVENT_FINISHED:        &finishedEvent{},
+	}
+	event, ok := events[header.Type]
+	if !ok {
+		t.Fatalf("Unknown event %s", data)
+	}
+	if err := json.Unmarshal(data, event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestEventStream(t *testing.T) {
+	server := httptest.NewServer(newGameServer())
+	defer server.Close()
+
+	start := createTestGame(t, server.URL, 3, 107)
+	gameUrl := server.URL + "/games/" + start.Id
+	client := dialWebSocket(t, gameUrl+"/events")
+	defer client.close(WS_CLOSE_NORMAL, "")
+
+	if e, ok := readEvent(t, client).(*stateEvent); !ok || e.State.DrawnTile != start.DrawnTile {
+		t.Fatalf("Expected the state first, got %+v", e)
+	}
+
+	// The same game is played next to the server, to know what to expect
+	game := generateInitialBoard(3, 107)
+	state := start
+	for !state.Finished {
+		_, moves, _ := game.drawTile()
+		notation, _ := game.moveNotation(moves[0])
+		state = gameResponse{}
+		request(t, "POST", gameUrl+"/moves", moveRequest{notation}, &state)
+		game.makeMove(moves[0])
+		last := game.lastMoves[len(game.lastMoves)-1]
+
+		if e, ok := readEvent(t, client).(*moveEvent); !ok || e.Move != notation || e.Player != last.currentPlayer || e.Tile.pos() != moves[0].pos {
+			t.Fatalf("Expected the move %v, got %+v", notation, e)
+		}
+		checkScoreEvents(t, client, game, last)
+
+		// Drawing again in the next round gives the same tile
+		if tile, _, ok := game.drawTile(); ok {
+			e, ok := readEvent(t, client).(*turnEvent)
+			if !ok || e.Player != game.currentPlayer || e.Tile != game.tileSet.tileName(tile.id) {
+				t.Fatalf("Expected the turn of %v, got %+v", game.currentPlayer, e)
+			}
+		}
+	}
+
+	game.finalizeGame()
+	checkScoreEvents(t, client, game, game.lastMoves[len(game.lastMoves)-1])
+	e, ok := readEvent(t, client).(*finishedEvent)
+	if !ok || fmt.Sprint(e.Winners) != fmt.Sprint(game.winners()) {
+		t.Fatalf("Expected the end of the game, got %+v", e)
+	}
+	for i, p := range game.players {
+		if e.Scores[i] != p.score {
+			t.Errorf("Player %v: expected %v points, got %v", i, p.score, e.Scores[i])
+		}
+	}
+
+	// Undo sends the whole state again
+	request(t, "POST", gameUrl+"/undo", nil, nil)
+	if e, ok := readEvent(t, client).(*stateEvent); !ok || e.State.Finished || len(e.State.Moves) != len(state.Moves)-1 {
+		t.Fatalf("Expected the state after undo, got %+v", e)
+	}
+
+	// Deleting the game closes the stream
+	request(t, "DELETE", gameUrl, nil, nil)
+	if _, _, err := client.readMessage(); err != errWebSocketClosed {
+		t.Errorf("Expected the stream to be closed, got %v", err)
+	}
+}
+
+// Reads the score and meeple events of the move.
+func checkScoreEvents(t *testing.T, client *wsConn, game GameState, m ReverseMove) {
+	for _, p := range m.awardedPoints {
+		if e, ok := readEvent(t, client).(*scoreEvent); !ok || e.Player != p.playerIndex || e.Points != p.points {
+			t.Fatalf("Expected %v points for player %v, got %+v", p.points, p.playerIndex, e)
+		}
+	}
+	for _, r := range m.playerToBoardMeeple {
+		if e, ok := readEvent(t, client).(*meepleReturnedEvent); !ok || e.pos() != r.pos || e.Player != r.playerIndex {
+			t.Fatalf("Expected the meeple at %v to return, got %+v", r.pos, e)
+		}
+	}
+}
+
+func TestEventStreamSpectators(t *testing.T) {
+	server := httptest.NewServer(newGameServer())
+	defer server.Close()
+	state := createTestGame(t, server.URL, 2, 3)
+	gameUrl := server.URL + "/games/" + state.Id
+
+	var clients []*wsConn
+	for i := 0; i < 3; i++ {
+		client := dialWebSocket(t, gameUrl+"/events")
+		defer client.close(WS_CLOSE_NORMAL, "")
+		readEvent(t, client)
+		clients = append(clients, client)
+	}
+	// One spectator leaves, the others still get the move
+	clients[0].close(WS_CLOSE_NORMAL, "")
+
+	var legal movesResponse
+	request(t, "GET", gameUrl+"/moves", nil, &legal)
+	request(t, "POST", gameUrl+"/moves", moveRequest{legal.Moves[0].Notation}, nil)
+	for _, client := range clients[1:] {
+		if e, ok := readEvent(t, client).(*moveEvent); !ok || e.Move != legal.Moves[0].Notation {
+			t.Errorf("Expected the move %v, got %+v", legal.Moves[0].Notation, e)
+		}
+	}
+}